@@ -0,0 +1,56 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/roman-mazur/architecture-lab-3/painter/lang"
+)
+
+// paintcheck перевіряє скрипти для painter без їх виконання. Без аргументів скрипт читається зі стандартного вводу.
+// Для кожної знайденої помилки чи попередження друкується її місце та рядок скрипта з позначкою під помилкою.
+// Код завершення 1 означає, що у скриптах є помилки; самі попередження його не змінюють.
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: paintcheck [script...]")
+	}
+	flag.Parse()
+
+	ok := true
+	if flag.NArg() == 0 {
+		ok = check("<stdin>", os.Stdin)
+	}
+	for _, name := range flag.Args() {
+		f, err := os.Open(name)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			ok = false
+			continue
+		}
+		ok = check(name, f) && ok
+		_ = f.Close()
+	}
+
+	if !ok {
+		os.Exit(1)
+	}
+}
+
+// check розбирає скрипт та друкує усі знайдені проблеми. Повертає false, якщо серед них є помилки.
+func check(name string, in io.Reader) bool {
+	var parser lang.Parser
+	_, diags := parser.ParseDiagnostics(in)
+
+	for _, d := range diags {
+		fmt.Fprintf(os.Stderr, "%s:%d:%d: %s: %s\n", name, d.Line, d.Column, d.Severity, d.Message)
+		if d.Source != "" {
+			fmt.Fprintln(os.Stderr, d.Caret())
+		}
+		if d.Expected != "" {
+			fmt.Fprintf(os.Stderr, "\texpected %s, got %s\n", d.Expected, d.Got)
+		}
+	}
+	return diags.Err() == nil
+}
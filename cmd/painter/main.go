@@ -1,6 +1,8 @@
 package main
 
 import (
+	"flag"
+	"log"
 	"net/http"
 
 	"github.com/roman-mazur/architecture-lab-3/painter"
@@ -8,7 +10,14 @@ import (
 	"github.com/roman-mazur/architecture-lab-3/ui"
 )
 
+var (
+	boundsPolicy = flag.String("bounds", "allow", "policy for figures crossing the texture edges: allow, clamp or reject")
+	scriptsDir   = flag.String("scripts", "", "directory with the script library used by include and /scripts")
+)
+
 func main() {
+	flag.Parse()
+
 	var (
 		pv ui.Visualizer // Візуалізатор створює вікно та малює у ньому.
 
@@ -17,6 +26,15 @@ func main() {
 		parser lang.Parser  // Парсер команд.
 	)
 
+	var err error
+	if opLoop.Bounds, err = painter.ParseBoundsPolicy(*boundsPolicy); err != nil {
+		log.Fatal(err)
+	}
+
+	if *scriptsDir != "" {
+		parser.Scripts = &lang.Library{Dir: *scriptsDir}
+	}
+
 	//pv.Debug = true
 	pv.Title = "Simple painter"
 
@@ -25,6 +43,16 @@ func main() {
 
 	go func() {
 		http.Handle("/", lang.HttpHandler(&opLoop, &parser))
+		http.Handle("/scene", lang.SceneHandler(&opLoop))
+		http.Handle("/scene/at", lang.HitTestHandler(&opLoop))
+		http.Handle("/scene/intersect", lang.IntersectHandler(&opLoop))
+		http.Handle("/filters", lang.FiltersHandler(&opLoop))
+		http.Handle("/procs", lang.ProcsHandler(&parser))
+		http.Handle("/procs/", lang.ProcsHandler(&parser))
+		http.Handle("/scripts", lang.ScriptsHandler(&opLoop, &parser))
+		http.Handle("/scripts/", lang.ScriptsHandler(&opLoop, &parser))
+		http.Handle("/template", lang.TemplateHandler(&opLoop, &parser))
+		http.Handle("/commands.schema.json", lang.SchemaHandler())
 		_ = http.ListenAndServe("localhost:17000", nil)
 	}()
 
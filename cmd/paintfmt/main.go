@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/roman-mazur/architecture-lab-3/painter/lang"
+)
+
+var (
+	write = flag.Bool("w", false, "write result to the script file instead of stdout")
+	list  = flag.Bool("l", false, "list files whose formatting differs")
+)
+
+// paintfmt приводить скрипти для painter до канонічного вигляду. Без аргументів скрипт читається зі стандартного
+// вводу, а результат друкується у стандартний вивід.
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: paintfmt [-w] [-l] [script...]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		if err := format("<stdin>", os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	ok := true
+	for _, name := range flag.Args() {
+		if err := formatFile(name); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			ok = false
+		}
+	}
+	if !ok {
+		os.Exit(1)
+	}
+}
+
+// formatFile форматує файл name відповідно до прапорців -w та -l.
+func formatFile(name string) error {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	res, err := lang.FormatScript(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+
+	changed := res != string(data)
+	if *list && changed {
+		fmt.Println(name)
+	}
+	if *write {
+		if changed {
+			return os.WriteFile(name, []byte(res), 0o644)
+		}
+		return nil
+	}
+	if !*list {
+		_, err = io.WriteString(os.Stdout, res)
+	}
+	return err
+}
+
+func format(name string, in io.Reader, out io.Writer) error {
+	res, err := lang.FormatScript(in)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	_, err = io.WriteString(out, res)
+	return err
+}
@@ -0,0 +1,148 @@
+package painter
+
+import (
+	"fmt"
+	"image"
+)
+
+// BoundsPolicy визначає, як обробляються фігури, що частково або повністю виходять за межі текстури.
+type BoundsPolicy int
+
+const (
+	BoundsAllow  BoundsPolicy = iota // Фігури розміщуються як є
+	BoundsClamp                      // Фігури зсуваються так, щоб бути видимими повністю
+	BoundsReject                     // Операції, що виводять фігури за межі, ігноруються
+)
+
+var boundsPolicyNames = map[BoundsPolicy]string{
+	BoundsAllow:  "allow",
+	BoundsClamp:  "clamp",
+	BoundsReject: "reject",
+}
+
+func (p BoundsPolicy) String() string {
+	return boundsPolicyNames[p]
+}
+
+// ParseBoundsPolicy повертає політику меж за її назвою: allow, clamp або reject.
+func ParseBoundsPolicy(name string) (BoundsPolicy, error) {
+	for p, n := range boundsPolicyNames {
+		if n == name {
+			return p, nil
+		}
+	}
+	return BoundsAllow, fmt.Errorf("unknown bounds policy %q", name)
+}
+
+// MaxFigures обмежує кількість фігур на сцені. Операції, що додали б більше фігур, відхиляються за будь-якої
+// політики меж.
+const MaxFigures = 100000
+
+// BoundsError повідомляє про фігуру, розміщення якої порушило політику меж.
+type BoundsError struct {
+	Op      string  `json:"op"`
+	ID      int     `json:"id,omitempty"` // Нуль для нової фігури
+	X       float32 `json:"x"`
+	Y       float32 `json:"y"`
+	Clamped bool    `json:"clamped"`         // Фігуру було зсунуто замість відхилення операції
+	Limit   bool    `json:"limit,omitempty"` // Фігури не додано, бо на сцені вже MaxFigures фігур
+}
+
+func (e BoundsError) Error() string {
+	if e.Limit {
+		return fmt.Sprintf("%s: scene already has %d figures, rejected", e.Op, MaxFigures)
+	}
+	action := "rejected"
+	if e.Clamped {
+		action = "clamped"
+	}
+	return fmt.Sprintf("%s: figure at (%g, %g) is out of bounds, %s", e.Op, e.X, e.Y, action)
+}
+
+// reserveFigures перевіряє, чи на сцену можна додати ще n фігур, не перевищивши MaxFigures.
+// Якщо ні, записує порушення для операції op.
+func (s *TextureState) reserveFigures(op string, n int) bool {
+	if len(s.figureCenters)+n <= MaxFigures {
+		return true
+	}
+	s.violations = append(s.violations, BoundsError{Op: op, Limit: true})
+	return false
+}
+
+// placeFigures присвоює фігурам нові значення moved з урахуванням політики меж.
+// Для BoundsReject зміна відхиляється повністю, якщо хоча б одна фігура виходить за межі.
+func (s *TextureState) placeFigures(op string, figs []*Figure, moved []Figure) bool {
+	var violations []BoundsError
+	for i := range moved {
+		if s.bounds == BoundsAllow || moved[i].visible() {
+			continue
+		}
+		err := BoundsError{Op: op, ID: s.idOf(figs[i]), X: moved[i].X, Y: moved[i].Y}
+		if s.bounds == BoundsClamp {
+			err.Clamped = true
+			moved[i].clamp()
+		}
+		violations = append(violations, err)
+	}
+	s.violations = append(s.violations, violations...)
+
+	if s.bounds == BoundsReject && len(violations) > 0 {
+		return false
+	}
+	for i, fig := range figs {
+		*fig = moved[i]
+	}
+	return true
+}
+
+// takeViolations повертає накопичені порушення меж та очищує їх.
+func (s *TextureState) takeViolations() []BoundsError {
+	res := s.violations
+	s.violations = nil
+	return res
+}
+
+func (s *TextureState) idOf(fig *Figure) int {
+	for i, f := range s.figureCenters {
+		if f == fig {
+			return s.figureIDs[i]
+		}
+	}
+	return 0
+}
+
+// bounds повертає прямокутник, що обмежує фігуру, у пікселях текстури.
+func (op Figure) bounds() image.Rectangle {
+	rects := op.rects()
+	return rects[0].Union(rects[1])
+}
+
+// visible перевіряє, чи фігура повністю знаходиться в межах текстури.
+func (op Figure) visible() bool {
+	return op.bounds().In(image.Rectangle{Max: size})
+}
+
+// clamp зсуває фігуру так, щоб вона повністю знаходилась у межах текстури.
+// Фігура, більша за текстуру, вирівнюється по її центру.
+func (op *Figure) clamp() {
+	b := op.bounds()
+	p := toPixels(op.X, op.Y)
+	p.X += clampShift(b.Min.X, b.Max.X, size.X)
+	p.Y += clampShift(b.Min.Y, b.Max.Y, size.Y)
+
+	// Зсув на пів пікселя компенсує похибку округлення у toPixels.
+	op.X = (float32(p.X) + 0.5) / float32(size.X)
+	op.Y = (float32(p.Y) + 0.5) / float32(size.Y)
+}
+
+func clampShift(min, max, limit int) int {
+	switch {
+	case max-min > limit:
+		return (limit - min - max) / 2
+	case min < 0:
+		return -min
+	case max > limit:
+		return limit - max
+	}
+	return 0
+}
@@ -0,0 +1,43 @@
+package painter
+
+import (
+	"image"
+	"image/color"
+
+	"golang.org/x/exp/shiny/screen"
+	"golang.org/x/image/draw"
+)
+
+// Canvas програмна текстура, яка зберігає пікселі у пам'яті.
+// Цикл подій малює кадр на Canvas, а потім завантажує його у текстуру екрана.
+type Canvas struct {
+	img *image.RGBA
+}
+
+// NewCanvas створює програмну текстуру вказаного розміру.
+func NewCanvas(size image.Point) *Canvas {
+	return &Canvas{img: image.NewRGBA(image.Rectangle{Max: size})}
+}
+
+// RGBA повертає пікселі текстури.
+func (c *Canvas) RGBA() *image.RGBA {
+	return c.img
+}
+
+func (c *Canvas) Release() {}
+
+func (c *Canvas) Size() image.Point {
+	return c.img.Rect.Size()
+}
+
+func (c *Canvas) Bounds() image.Rectangle {
+	return c.img.Rect
+}
+
+func (c *Canvas) Upload(dp image.Point, src screen.Buffer, sr image.Rectangle) {
+	draw.Draw(c.img, sr.Sub(sr.Min).Add(dp), src.RGBA(), sr.Min, draw.Src)
+}
+
+func (c *Canvas) Fill(dr image.Rectangle, src color.Color, op draw.Op) {
+	draw.Draw(c.img, dr, &image.Uniform{C: src}, image.Point{}, op)
+}
@@ -0,0 +1,146 @@
+package painter
+
+import (
+	"fmt"
+	"image"
+
+	"golang.org/x/exp/shiny/screen"
+	"golang.org/x/image/draw"
+)
+
+// Clip область відсікання. Прямокутник сцени та фігури малюються лише всередині усіх областей стеку відсікання,
+// що діяв на момент їх додавання на сцену.
+// String повертає область у синтаксисі скриптів.
+type Clip interface {
+	// Contains перевіряє, чи піксель (x, y) текстури, що описується станом state, належить області.
+	Contains(x, y int, state *TextureState) bool
+	String() string
+}
+
+// ClipRect прямокутна область відсікання у нормалізованих координатах.
+type ClipRect struct {
+	X1 float32
+	Y1 float32
+	X2 float32
+	Y2 float32
+}
+
+func (c ClipRect) Contains(x, y int, _ *TextureState) bool {
+	return image.Pt(x, y).In(BgRect{X1: c.X1, Y1: c.Y1, X2: c.X2, Y2: c.Y2}.rect(size))
+}
+
+func (c ClipRect) String() string {
+	return fmt.Sprintf("rect %g %g %g %g", c.X1, c.Y1, c.X2, c.Y2)
+}
+
+// ClipCircle кругла область відсікання з центром (X, Y) та радіусом R у частках ширини текстури.
+type ClipCircle struct {
+	X float32
+	Y float32
+	R float32
+}
+
+func (c ClipCircle) Contains(x, y int, _ *TextureState) bool {
+	center := toPixels(c.X, c.Y)
+	r := float64(c.R) * float64(size.X)
+	dx, dy := float64(x-center.X), float64(y-center.Y)
+	return dx*dx+dy*dy <= r*r
+}
+
+func (c ClipCircle) String() string {
+	return fmt.Sprintf("circle %g %g %g", c.X, c.Y, c.R)
+}
+
+// ClipBgRect область відсікання, що збігається з поточним прямокутником сцени.
+// Якщо прямокутника немає, відсікається усе.
+type ClipBgRect struct{}
+
+func (c ClipBgRect) Contains(x, y int, state *TextureState) bool {
+	return state.backgroundRect != nil && image.Pt(x, y).In(state.backgroundRect.rect(size))
+}
+
+func (c ClipBgRect) String() string {
+	return "bgrect"
+}
+
+// PushClip операція додає область на вершину стеку відсікання.
+type PushClip struct {
+	Clip Clip
+}
+
+func (op PushClip) Update(state *TextureState) {
+	state.clips = append(state.clips, op.Clip)
+}
+
+// PopClip операція знімає верхню область зі стеку відсікання, або усі області, якщо All встановлено.
+type PopClip struct {
+	All bool
+}
+
+func (op PopClip) Update(state *TextureState) {
+	if op.All || len(state.clips) == 0 {
+		state.clips = nil
+		return
+	}
+	state.clips = state.clips[:len(state.clips)-1]
+}
+
+// activeClips повертає копію поточного стеку відсікання для нового прямокутника чи фігури.
+func (s *TextureState) activeClips() []Clip {
+	if len(s.clips) == 0 {
+		return nil
+	}
+	return append([]Clip(nil), s.clips...)
+}
+
+// clipped перевіряє, чи піксель (x, y) знаходиться поза стеком відсікання clips.
+func (s *TextureState) clipped(clips []Clip, x, y int) bool {
+	for _, c := range clips {
+		if !c.Contains(x, y, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// drawClipped викликає drawFn, що малює у текстуру t в межах області area, та відновлює попередні пікселі
+// тих місць області, що знаходяться поза стеком відсікання clips. Відсікання працює лише на програмній текстурі.
+func (s *TextureState) drawClipped(t screen.Texture, clips []Clip, area image.Rectangle, drawFn func()) {
+	canvas, ok := t.(*Canvas)
+	if !ok || len(clips) == 0 {
+		drawFn()
+		return
+	}
+
+	img := canvas.RGBA()
+	area = area.Intersect(img.Rect)
+	saved := image.NewRGBA(area)
+	draw.Draw(saved, area, img, area.Min, draw.Src)
+
+	drawFn()
+
+	for y := area.Min.Y; y < area.Max.Y; y++ {
+		for x := area.Min.X; x < area.Max.X; x++ {
+			if s.clipped(clips, x, y) {
+				i, j := img.PixOffset(x, y), saved.PixOffset(x, y)
+				copy(img.Pix[i:i+4], saved.Pix[j:j+4])
+			}
+		}
+	}
+}
+
+// effectArea повертає область, в якій малюється форма з межами r разом з контуром, тінню та світінням.
+func effectArea(r image.Rectangle, stroke *Stroke, shadow *Shadow, glow *Glow) image.Rectangle {
+	area := r
+	pad := 2 // Запас на згладжування країв
+	if stroke != nil {
+		pad += stroke.Width
+	}
+	if glow != nil {
+		area = area.Union(r.Inset(-glow.Radius))
+	}
+	if shadow != nil {
+		area = area.Union(r.Add(image.Pt(shadow.DX, shadow.DY)).Inset(-shadow.Blur))
+	}
+	return area.Inset(-pad)
+}
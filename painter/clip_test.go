@@ -0,0 +1,60 @@
+package painter
+
+import (
+	"image/color"
+	"reflect"
+	"testing"
+)
+
+func TestClipping(t *testing.T) {
+	var state TextureState
+	red := color.RGBA{R: 0xff, A: 0xff}
+	green := color.RGBA{G: 0xff, A: 0xff}
+	ops := OperationList{
+		Fill{Color: color.White},
+		BgRect{X1: 0.25, Y1: 0.25, X2: 0.75, Y2: 0.75},
+		PushClip{Clip: ClipBgRect{}},
+		PushClip{Clip: ClipCircle{X: 0.5, Y: 0.5, R: 0.2}},
+		Figure{X: 0.5, Y: 0.5, Color: color.Black},
+		PopClip{},
+		Figure{X: 0.5, Y: 0.9, Color: red},
+		PopClip{},
+		Figure{X: 0.2, Y: 0.15, Scale: 0.5, Color: green},
+	}
+	for _, op := range ops {
+		op.Update(&state)
+	}
+
+	canvas := NewCanvas(size)
+	state.draw(canvas)
+	img := canvas.RGBA()
+
+	white := color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+	black := color.RGBA{A: 0xff}
+	if img.RGBAAt(300, 250) != black {
+		t.Error("Figure inside the clip should be drawn")
+	}
+	if img.RGBAAt(100, 280) != white {
+		t.Error("Figure outside the clip should be cut")
+	}
+	if img.RGBAAt(160, 160) != black {
+		t.Error("Rect added before the clip should not be cut")
+	}
+	if img.RGBAAt(200, 400) != red || img.RGBAAt(100, 400) != white {
+		t.Error("Figure added after the pop should be cut only by the bgrect clip")
+	}
+	if img.RGBAAt(20, 50) != green {
+		t.Error("Figure added after unclip should not be cut")
+	}
+
+	scene := state.Scene()
+	if len(scene.Clips) != 0 {
+		t.Errorf("Clip stack should be empty: %v", scene.Clips)
+	}
+	expected := [][]string{{"bgrect", "circle 0.5 0.5 0.2"}, {"bgrect"}, nil}
+	for i, fig := range scene.Figures {
+		if !reflect.DeepEqual(fig.Clips, expected[i]) {
+			t.Errorf("Incorrect clips of figure %d: %v", fig.ID, fig.Clips)
+		}
+	}
+}
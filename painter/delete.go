@@ -0,0 +1,67 @@
+package painter
+
+// DeleteFigure операція видаляє фігуру з вказаним ідентифікатором.
+type DeleteFigure struct {
+	ID int
+}
+
+func (op DeleteFigure) Update(state *TextureState) {
+	state.removeFigures([]int{op.ID})
+}
+
+// DeleteIndex операція видаляє фігуру за її порядковим номером на сцені, починаючи з нуля.
+type DeleteIndex struct {
+	Index int
+}
+
+func (op DeleteIndex) Update(state *TextureState) {
+	if op.Index >= 0 && op.Index < len(state.figureIDs) {
+		state.removeFigures([]int{state.figureIDs[op.Index]})
+	}
+}
+
+// DeleteRect операція видаляє прямокутник сцени разом з його стилем.
+type DeleteRect struct{}
+
+func (op DeleteRect) Update(state *TextureState) {
+	state.removeRect()
+}
+
+// DeleteAt операція видаляє верхній об'єкт сцени, що містить точку з вказаними координатами.
+type DeleteAt struct {
+	X float32
+	Y float32
+}
+
+func (op DeleteAt) Update(state *TextureState) {
+	if i := state.figureAt(op.X, op.Y); i >= 0 {
+		state.removeFigures([]int{state.figureIDs[i]})
+	} else if state.backgroundRect != nil && state.backgroundRect.contains(op.X, op.Y) {
+		state.removeRect()
+	}
+}
+
+// ReplaceFigure операція за один крок замінює усі параметри фігури з вказаним ідентифікатором на Figure.
+// Ідентифікатор, належність до груп, рух та відсікання зберігаються.
+type ReplaceFigure struct {
+	ID     int
+	Figure Figure
+}
+
+func (op ReplaceFigure) Update(state *TextureState) {
+	if i := state.figureIndex(op.ID); i >= 0 {
+		state.placeFigures("replace", []*Figure{state.figureCenters[i]}, []Figure{op.Figure})
+	}
+}
+
+// ReplaceRect операція змінює координати наявного прямокутника сцени.
+type ReplaceRect struct {
+	Rect BgRect
+}
+
+func (op ReplaceRect) Update(state *TextureState) {
+	if state.backgroundRect != nil {
+		rect := op.Rect
+		state.backgroundRect = &rect
+	}
+}
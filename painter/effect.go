@@ -0,0 +1,169 @@
+package painter
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"sync"
+
+	"golang.org/x/image/draw"
+)
+
+// Shadow тінь фігури: зсув DX, DY та радіус розмиття Blur у пікселях.
+type Shadow struct {
+	DX    int
+	DY    int
+	Blur  int
+	Color color.Color
+}
+
+// String повертає тінь у синтаксисі скриптів.
+func (s *Shadow) String() string {
+	return fmt.Sprintf("%d %d %d %s", s.DX, s.DY, s.Blur, HexColor(s.Color))
+}
+
+// Glow зовнішнє світіння фігури з радіусом Radius у пікселях.
+type Glow struct {
+	Radius int
+	Color  color.Color
+}
+
+// String повертає світіння у синтаксисі скриптів.
+func (g *Glow) String() string {
+	return fmt.Sprintf("%d %s", g.Radius, HexColor(g.Color))
+}
+
+// SetShadow операція задає тінь прямокутника сцени, якщо встановлено Rect, фігури з ідентифікатором ID,
+// фігур групи Group або усіх фігур. Nil Shadow прибирає тінь.
+type SetShadow struct {
+	Rect   bool
+	ID     int
+	Group  string
+	Shadow *Shadow
+}
+
+func (op SetShadow) Update(state *TextureState) {
+	if op.Rect {
+		state.rectShadow = op.Shadow
+		return
+	}
+	for _, id := range state.selectFigures(op.ID, op.Group) {
+		state.figureCenters[state.figureIndex(id)].Shadow = op.Shadow
+	}
+}
+
+// SetGlow операція задає світіння прямокутника сцени або фігур. Nil Glow прибирає світіння.
+type SetGlow struct {
+	Rect  bool
+	ID    int
+	Group string
+	Glow  *Glow
+}
+
+func (op SetGlow) Update(state *TextureState) {
+	if op.Rect {
+		state.rectGlow = op.Glow
+		return
+	}
+	for _, id := range state.selectFigures(op.ID, op.Group) {
+		state.figureCenters[state.figureIndex(id)].Glow = op.Glow
+	}
+}
+
+// MaxEffectRadius найбільший радіус розмиття тіні та світіння у пікселях.
+const MaxEffectRadius = 100
+
+// maxMaskPixels обмежує розмір маски, щоб завеликі форми чи розмиття не вичерпали пам'ять.
+const maxMaskPixels = 4096 * 4096
+
+// maskKey описує форму та розмиття маски. Прямокутники форми задаються відносно її опорної точки.
+type maskKey struct {
+	rects  [2]image.Rectangle
+	blur   int
+	spread bool
+}
+
+// maxMasks обмежує розмір кешу масок. Переповнений кеш очищується повністю.
+const maxMasks = 64
+
+// masks кешує розмиті маски, адже фігури однакового масштабу мають однакову форму.
+var masks = struct {
+	sync.Mutex
+	m map[maskKey]*image.Alpha
+}{m: map[maskKey]*image.Alpha{}}
+
+// drawEffects малює світіння та тінь форми, що складається з прямокутників rects, під самою формою.
+func drawEffects(img *image.RGBA, rects []image.Rectangle, shadow *Shadow, glow *Glow) {
+	origin := rects[0].Min
+	var key maskKey
+	for i, r := range rects {
+		key.rects[i] = r.Sub(origin)
+	}
+
+	if glow != nil {
+		key.blur, key.spread = glow.Radius, true
+		if mask := shapeMask(key); mask != nil {
+			compositeMask(img, mask, origin, glow.Color)
+		}
+	}
+	if shadow != nil {
+		key.blur, key.spread = shadow.Blur, false
+		if mask := shapeMask(key); mask != nil {
+			compositeMask(img, mask, origin.Add(image.Pt(shadow.DX, shadow.DY)), shadow.Color)
+		}
+	}
+}
+
+// shapeMask повертає розмиту маску форми з кешу, створюючи її за потреби.
+// Повертає nil, якщо розмиття більше за MaxEffectRadius або маска завелика.
+func shapeMask(key maskKey) *image.Alpha {
+	if key.blur < 0 || key.blur > MaxEffectRadius {
+		return nil
+	}
+	bounds := key.rects[0].Union(key.rects[1]).Inset(-key.blur)
+	if bounds.Dx()*bounds.Dy() > maxMaskPixels {
+		return nil
+	}
+
+	masks.Lock()
+	defer masks.Unlock()
+
+	if mask, ok := masks.m[key]; ok {
+		return mask
+	}
+
+	mask := image.NewAlpha(bounds)
+	for _, r := range key.rects {
+		draw.Draw(mask, r, image.Opaque, image.Point{}, draw.Src)
+	}
+
+	if key.blur > 0 {
+		b := mask.Rect
+		tmp := make([]uint8, len(mask.Pix))
+		boxBlur(mask.Pix, tmp, b.Dx(), b.Dy(), mask.Stride, 1, 1, key.blur)
+		boxBlur(tmp, mask.Pix, b.Dy(), b.Dx(), 1, mask.Stride, 1, key.blur)
+	}
+
+	// Світіння підсилюється, щоб бути помітним навколо краю форми.
+	if key.spread {
+		for i, a := range mask.Pix {
+			if a > 0x7f {
+				mask.Pix[i] = 0xff
+			} else {
+				mask.Pix[i] = a * 2
+			}
+		}
+	}
+
+	if len(masks.m) >= maxMasks {
+		masks.m = map[maskKey]*image.Alpha{}
+	}
+	masks.m[key] = mask
+	return mask
+}
+
+// compositeMask накладає колір c через маску, зсунуту в точку origin.
+func compositeMask(img *image.RGBA, mask *image.Alpha, origin image.Point, c color.Color) {
+	dr := mask.Rect.Add(origin)
+	draw.DrawMask(img, dr, &image.Uniform{C: c}, image.Point{}, mask, mask.Rect.Min, draw.Over)
+}
@@ -0,0 +1,57 @@
+package painter
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestShadowAndGlow(t *testing.T) {
+	// Кеш масок спільний для усіх тестів, тому він очищується перед перевіркою кількості масок.
+	masks.Lock()
+	masks.m = map[maskKey]*image.Alpha{}
+	masks.Unlock()
+
+	var state TextureState
+	ops := OperationList{
+		Fill{Color: color.White},
+		Figure{X: 0.5, Y: 0.45, Color: color.RGBA{R: 0xff, A: 0xff}},
+		Figure{X: 0.5, Y: 0.45, Color: color.RGBA{R: 0xff, A: 0xff}},
+		SetShadow{ID: 1, Shadow: &Shadow{DX: 20, DY: 20, Blur: 4, Color: color.Black}},
+		SetGlow{ID: 2, Glow: &Glow{Radius: 6, Color: color.RGBA{B: 0xff, A: 0xff}}},
+	}
+	for _, op := range ops {
+		op.Update(&state)
+	}
+
+	canvas := NewCanvas(size)
+	state.draw(canvas)
+	img := canvas.RGBA()
+
+	// Перекладина T закінчується на y=270, тінь зсунута на 20 пікселів вниз.
+	if c := img.RGBAAt(100, 280); c.R > 0x40 || c.B > 0x40 {
+		t.Errorf("Shadow is missing: %v", c)
+	}
+	// Над фігурою є лише світіння.
+	if c := img.RGBAAt(300, 93); c.B != 0xff || c.R == 0xff {
+		t.Errorf("Glow is missing: %v", c)
+	}
+	if c := img.RGBAAt(300, 200); c != (color.RGBA{R: 0xff, A: 0xff}) {
+		t.Errorf("Figure should be drawn above effects: %v", c)
+	}
+
+	if len(masks.m) != 2 {
+		t.Errorf("Masks should be cached, got %d", len(masks.m))
+	}
+}
+
+func TestShapeMaskLimits(t *testing.T) {
+	key := maskKey{rects: [2]image.Rectangle{image.Rect(0, 0, 10, 10)}, blur: MaxEffectRadius + 1}
+	if mask := shapeMask(key); mask != nil {
+		t.Errorf("Mask with blur above MaxEffectRadius should be refused, got %v", mask.Rect)
+	}
+	key = maskKey{rects: [2]image.Rectangle{image.Rect(0, 0, 1<<20, 1<<20)}}
+	if mask := shapeMask(key); mask != nil {
+		t.Errorf("Oversized mask should be refused, got %v", mask.Rect)
+	}
+}
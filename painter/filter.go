@@ -0,0 +1,229 @@
+package painter
+
+import (
+	"fmt"
+	"image"
+)
+
+// Filter змінює готовий кадр перед відправкою у Receiver. String повертає фільтр у синтаксисі скриптів.
+type Filter interface {
+	Apply(img *image.RGBA)
+	String() string
+}
+
+// AddFilter операція додає фільтр у кінець ланцюжка фільтрів.
+type AddFilter struct {
+	Filter Filter
+}
+
+func (op AddFilter) Update(state *TextureState) {
+	state.filters = append(state.filters, op.Filter)
+}
+
+// SetFilters операція замінює увесь ланцюжок фільтрів. Порожній список вимикає фільтри.
+type SetFilters struct {
+	Filters []Filter
+}
+
+func (op SetFilters) Update(state *TextureState) {
+	state.filters = append([]Filter(nil), op.Filters...)
+}
+
+// Grayscale переводить кадр у відтінки сірого.
+type Grayscale struct{}
+
+func (f Grayscale) Apply(img *image.RGBA) {
+	mapPixels(img, func(r, g, b float32) (float32, float32, float32) {
+		y := 0.299*r + 0.587*g + 0.114*b
+		return y, y, y
+	})
+}
+
+func (f Grayscale) String() string {
+	return "grayscale"
+}
+
+// Invert інвертує кольори кадру.
+type Invert struct{}
+
+func (f Invert) Apply(img *image.RGBA) {
+	mapPixels(img, func(r, g, b float32) (float32, float32, float32) {
+		return 0xff - r, 0xff - g, 0xff - b
+	})
+}
+
+func (f Invert) String() string {
+	return "invert"
+}
+
+// Brightness змінює яскравість кадру на Amount, від -1 до 1.
+type Brightness struct {
+	Amount float32
+}
+
+func (f Brightness) Apply(img *image.RGBA) {
+	mapPixels(img, func(r, g, b float32) (float32, float32, float32) {
+		d := f.Amount * 0xff
+		return r + d, g + d, b + d
+	})
+}
+
+func (f Brightness) String() string {
+	return fmt.Sprintf("brightness %g", f.Amount)
+}
+
+// Contrast змінює контраст кадру у Factor разів відносно середньої яскравості.
+type Contrast struct {
+	Factor float32
+}
+
+func (f Contrast) Apply(img *image.RGBA) {
+	mapPixels(img, func(r, g, b float32) (float32, float32, float32) {
+		const mid = 0xff / 2.0
+		return (r-mid)*f.Factor + mid, (g-mid)*f.Factor + mid, (b-mid)*f.Factor + mid
+	})
+}
+
+func (f Contrast) String() string {
+	return fmt.Sprintf("contrast %g", f.Factor)
+}
+
+// Матриці перетворення кольорів для кожного виду порушення кольоросприйняття.
+var colorBlindMatrices = map[string][3][3]float32{
+	"protanopia":   {{0.567, 0.433, 0}, {0.558, 0.442, 0}, {0, 0.242, 0.758}},
+	"deuteranopia": {{0.625, 0.375, 0}, {0.7, 0.3, 0}, {0, 0.3, 0.7}},
+	"tritanopia":   {{0.95, 0.05, 0}, {0, 0.433, 0.567}, {0, 0.475, 0.525}},
+}
+
+// ColorBlind імітує сприйняття кадру людиною з порушенням кольоросприйняття Kind:
+// protanopia, deuteranopia або tritanopia.
+type ColorBlind struct {
+	Kind string
+}
+
+// NewColorBlind повертає фільтр для вказаного виду порушення кольоросприйняття.
+func NewColorBlind(kind string) (ColorBlind, error) {
+	if _, ok := colorBlindMatrices[kind]; !ok {
+		return ColorBlind{}, fmt.Errorf("unknown color blindness %q", kind)
+	}
+	return ColorBlind{Kind: kind}, nil
+}
+
+func (f ColorBlind) Apply(img *image.RGBA) {
+	m := colorBlindMatrices[f.Kind]
+	mapPixels(img, func(r, g, b float32) (float32, float32, float32) {
+		return m[0][0]*r + m[0][1]*g + m[0][2]*b,
+			m[1][0]*r + m[1][1]*g + m[1][2]*b,
+			m[2][0]*r + m[2][1]*g + m[2][2]*b
+	})
+}
+
+func (f ColorBlind) String() string {
+	return "colorblind " + f.Kind
+}
+
+// Blur розмиває кадр квадратним ядром з радіусом Radius пікселів.
+type Blur struct {
+	Radius int
+}
+
+func (f Blur) Apply(img *image.RGBA) {
+	if f.Radius <= 0 {
+		return
+	}
+	b := img.Rect
+	tmp := make([]uint8, len(img.Pix))
+	boxBlur(img.Pix, tmp, b.Dx(), b.Dy(), img.Stride, 4, 4, f.Radius)
+	boxBlur(tmp, img.Pix, b.Dy(), b.Dx(), 4, img.Stride, 4, f.Radius)
+}
+
+func (f Blur) String() string {
+	return fmt.Sprintf("blur %d", f.Radius)
+}
+
+// boxBlur усереднює пікселі з channels компонентів вздовж рядків довжиною n.
+// Крок між рядками lineStep, між пікселями рядка pixStep.
+func boxBlur(src, dst []uint8, n, lines, lineStep, pixStep, channels, radius int) {
+	for line := 0; line < lines; line++ {
+		base := line * lineStep
+		for c := 0; c < channels; c++ {
+			var sum, count int
+			for i := 0; i < radius && i < n; i++ {
+				sum += int(src[base+i*pixStep+c])
+				count++
+			}
+			for i := 0; i < n; i++ {
+				if j := i + radius; j < n {
+					sum += int(src[base+j*pixStep+c])
+					count++
+				}
+				if j := i - radius - 1; j >= 0 {
+					sum -= int(src[base+j*pixStep+c])
+					count--
+				}
+				dst[base+i*pixStep+c] = uint8(sum / count)
+			}
+		}
+	}
+}
+
+// Pixelate замінює кожен блок Size на Size пікселів його середнім кольором.
+type Pixelate struct {
+	Size int
+}
+
+func (f Pixelate) Apply(img *image.RGBA) {
+	if f.Size <= 1 {
+		return
+	}
+	b := img.Rect
+	for y0 := b.Min.Y; y0 < b.Max.Y; y0 += f.Size {
+		for x0 := b.Min.X; x0 < b.Max.X; x0 += f.Size {
+			block := image.Rect(x0, y0, x0+f.Size, y0+f.Size).Intersect(b)
+
+			var sum [4]int
+			for y := block.Min.Y; y < block.Max.Y; y++ {
+				for x := block.Min.X; x < block.Max.X; x++ {
+					i := img.PixOffset(x, y)
+					for c := 0; c < 4; c++ {
+						sum[c] += int(img.Pix[i+c])
+					}
+				}
+			}
+
+			n := block.Dx() * block.Dy()
+			for y := block.Min.Y; y < block.Max.Y; y++ {
+				for x := block.Min.X; x < block.Max.X; x++ {
+					i := img.PixOffset(x, y)
+					for c := 0; c < 4; c++ {
+						img.Pix[i+c] = uint8(sum[c] / n)
+					}
+				}
+			}
+		}
+	}
+}
+
+func (f Pixelate) String() string {
+	return fmt.Sprintf("pixelate %d", f.Size)
+}
+
+// mapPixels застосовує fn до кольорових компонентів кожного пікселя, обмежуючи результат діапазоном [0, 255].
+// Кадр вважається непрозорим, тому альфа-канал не змінюється і не враховується.
+func mapPixels(img *image.RGBA, fn func(r, g, b float32) (float32, float32, float32)) {
+	for i := 0; i+3 < len(img.Pix); i += 4 {
+		p := img.Pix[i : i+3 : i+3]
+		r, g, b := fn(float32(p[0]), float32(p[1]), float32(p[2]))
+		p[0], p[1], p[2] = clampComponent(r), clampComponent(g), clampComponent(b)
+	}
+}
+
+func clampComponent(v float32) uint8 {
+	switch {
+	case v < 0:
+		return 0
+	case v > 0xff:
+		return 0xff
+	}
+	return uint8(v + 0.5)
+}
@@ -0,0 +1,52 @@
+package painter
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestFilterChain(t *testing.T) {
+	var state TextureState
+	ops := OperationList{
+		Fill{Color: color.RGBA{R: 0xff, A: 0xff}},
+		AddFilter{Filter: Grayscale{}},
+		AddFilter{Filter: Invert{}},
+	}
+	for _, op := range ops {
+		op.Update(&state)
+	}
+
+	canvas := NewCanvas(size)
+	state.draw(canvas)
+
+	// Червоний у відтінках сірого має яскравість 76, після інверсії — 179.
+	if c := canvas.RGBA().RGBAAt(0, 0); c != (color.RGBA{R: 179, G: 179, B: 179, A: 0xff}) {
+		t.Errorf("Incorrect filtered color: %v", c)
+	}
+
+	SetFilters{}.Update(&state)
+	state.draw(canvas)
+	if c := canvas.RGBA().RGBAAt(0, 0); c != (color.RGBA{R: 0xff, A: 0xff}) {
+		t.Errorf("Filters were not cleared: %v", c)
+	}
+}
+
+func TestBlurAndPixelate(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 4, 1))
+	img.SetRGBA(0, 0, color.RGBA{R: 0xff, A: 0xff})
+	img.SetRGBA(1, 0, color.RGBA{R: 0xff, A: 0xff})
+
+	Pixelate{Size: 4}.Apply(img)
+	if c := img.RGBAAt(3, 0); c.R != 0x7f || c.A != 0x7f {
+		t.Errorf("Incorrect pixelated color: %v", c)
+	}
+
+	img.SetRGBA(0, 0, color.RGBA{R: 0xff, A: 0xff})
+	img.SetRGBA(1, 0, color.RGBA{})
+	img.SetRGBA(2, 0, color.RGBA{})
+	Blur{Radius: 1}.Apply(img)
+	if c := img.RGBAAt(1, 0); c.R != 0xff/3 {
+		t.Errorf("Incorrect blurred color: %v", c)
+	}
+}
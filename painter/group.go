@@ -0,0 +1,84 @@
+package painter
+
+import "image/color"
+
+// Group операція об'єднує фігури та інші групи під спільною назвою.
+// Учасники задаються ідентифікаторами фігур або назвами вкладених груп.
+type Group struct {
+	Name    string
+	Members []string
+}
+
+func (op Group) Update(state *TextureState) {
+	if state.groups == nil {
+		state.groups = map[string][]string{}
+	}
+	state.groups[op.Name] = append([]string(nil), op.Members...)
+}
+
+// GroupMove операція переміщує групу так, щоб її центр опинився у вказаних координатах.
+// Взаємне розташування фігур групи зберігається.
+type GroupMove struct {
+	Name string
+	X    float32
+	Y    float32
+}
+
+func (op GroupMove) Update(state *TextureState) {
+	ids := state.groupFigures(op.Name)
+	if len(ids) == 0 {
+		return
+	}
+
+	cx, cy := state.groupCenter(ids)
+	figs, moved := state.figuresByID(ids)
+	for i := range moved {
+		moved[i].X += op.X - cx
+		moved[i].Y += op.Y - cy
+	}
+	state.placeFigures("move", figs, moved)
+}
+
+// GroupScale операція змінює розмір групи у Factor разів відносно її центру.
+type GroupScale struct {
+	Name   string
+	Factor float32
+}
+
+func (op GroupScale) Update(state *TextureState) {
+	ids := state.groupFigures(op.Name)
+	if len(ids) == 0 {
+		return
+	}
+
+	cx, cy := state.groupCenter(ids)
+	figs, moved := state.figuresByID(ids)
+	for i := range moved {
+		moved[i].X = cx + (moved[i].X-cx)*op.Factor
+		moved[i].Y = cy + (moved[i].Y-cy)*op.Factor
+		moved[i].Scale = moved[i].scale() * op.Factor
+	}
+	state.placeFigures("scale", figs, moved)
+}
+
+// GroupColor операція перефарбовує усі фігури групи.
+type GroupColor struct {
+	Name  string
+	Color color.Color
+}
+
+func (op GroupColor) Update(state *TextureState) {
+	for _, id := range state.groupFigures(op.Name) {
+		state.figureCenters[state.figureIndex(id)].Color = op.Color
+	}
+}
+
+// GroupDelete операція видаляє усі фігури групи разом із самою групою.
+type GroupDelete struct {
+	Name string
+}
+
+func (op GroupDelete) Update(state *TextureState) {
+	state.removeFigures(state.groupFigures(op.Name))
+	delete(state.groups, op.Name)
+}
@@ -0,0 +1,91 @@
+package painter
+
+import (
+	"image"
+
+	"github.com/roman-mazur/architecture-lab-3/ui"
+)
+
+// Види об'єктів, які повертає пошук по сцені.
+const (
+	HitFigure = "figure"
+	HitRect   = "rect"
+)
+
+// Hit об'єкт сцени, знайдений під час пошуку.
+type Hit struct {
+	Kind   string       `json:"kind"`
+	Figure *SceneFigure `json:"figure,omitempty"`
+	Rect   *SceneRect   `json:"rect,omitempty"`
+}
+
+// HitTest повертає об'єкти сцени, що містять точку (x, y), починаючи з верхнього.
+// Для фігур враховується справжній контур T, а не лише центр.
+func (s *TextureState) HitTest(x, y float32) []Hit {
+	var res []Hit
+	p := toPixels(x, y)
+
+	for i := len(s.figureCenters) - 1; i >= 0; i-- {
+		if s.figureCenters[i].contains(p) {
+			fig := s.sceneFigure(i)
+			res = append(res, Hit{Kind: HitFigure, Figure: &fig})
+		}
+	}
+
+	if r := s.backgroundRect; r != nil && r.contains(x, y) {
+		res = append(res, Hit{Kind: HitRect, Rect: &SceneRect{X1: r.X1, Y1: r.Y1, X2: r.X2, Y2: r.Y2}})
+	}
+
+	return res
+}
+
+// FiguresIn повертає фігури, контур яких перетинається з прямокутником (x1, y1)-(x2, y2).
+func (s *TextureState) FiguresIn(x1, y1, x2, y2 float32) []SceneFigure {
+	res := []SceneFigure{}
+	area := image.Rectangle{Min: toPixels(x1, y1), Max: toPixels(x2, y2)}.Canon()
+
+	for i, fig := range s.figureCenters {
+		for _, r := range fig.rects() {
+			if r.Overlaps(area) {
+				res = append(res, s.sceneFigure(i))
+				break
+			}
+		}
+	}
+
+	return res
+}
+
+// figureAt повертає позицію верхньої фігури, що містить точку, або -1, якщо такої немає.
+func (s *TextureState) figureAt(x, y float32) int {
+	p := toPixels(x, y)
+	for i := len(s.figureCenters) - 1; i >= 0; i-- {
+		if s.figureCenters[i].contains(p) {
+			return i
+		}
+	}
+	return -1
+}
+
+// rects повертає прямокутники фігури у пікселях текстури.
+func (op Figure) rects() [2]image.Rectangle {
+	return ui.TRects(toPixels(op.X, op.Y), float64(op.scale()))
+}
+
+func (op Figure) contains(p image.Point) bool {
+	for _, r := range op.rects() {
+		if p.In(r) {
+			return true
+		}
+	}
+	return false
+}
+
+func (op BgRect) contains(x, y float32) bool {
+	return x >= op.X1 && x < op.X2 && y >= op.Y1 && y < op.Y2
+}
+
+// toPixels переводить нормалізовані координати у пікселі текстури.
+func toPixels(x, y float32) image.Point {
+	return image.Pt(int(x*float32(size.X)), int(y*float32(size.Y)))
+}
@@ -0,0 +1,387 @@
+package lang
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// token слово скрипта разом з його позицією.
+type token struct {
+	text string
+	line int
+	col  int // Номер символу у рядку, починаючи з 1
+}
+
+// splitCommands розбиває рядок скрипта на команди, розділені ';', а команди на слова, розділені пробілами.
+// Дужки '{' та '}' блоків також розділяють команди і повертаються як окремі команди з одного слова.
+// Коментар починається з "//" або '#' на початку слова та триває до кінця рядка. Слово, що є кольором
+// у форматі #rgb, #rrggbb чи #rrggbbaa (можливо з "@<зсув>" опорної точки), не починає коментар, тому коментар
+// після аргументів, що складається з шістнадцяткових цифр, слід відокремлювати пробілом: "white # abc".
+// Порожні команди пропускаються.
+func splitCommands(line string, lineNum int) [][]token {
+	res, _ := splitLine(line, lineNum)
+	return res
+}
+
+// splitLine розбиває рядок скрипта на команди так само, як splitCommands, та додатково повертає коментар
+// разом з його початком "#" чи "//", або порожній рядок, якщо коментаря немає.
+func splitLine(line string, lineNum int) ([][]token, string) {
+	var (
+		res     [][]token
+		cmd     []token
+		comment string
+		start   = -1 // Початок поточного слова у байтах
+		col     = 0  // Номер поточного символу, починаючи з 0
+		first   = 0  // Номер першого символу поточного слова
+	)
+
+	endCommand := func() {
+		if len(cmd) > 0 {
+			res = append(res, cmd)
+		}
+		cmd = nil
+	}
+
+scan:
+	for i, r := range line {
+		col++
+		delim := r == ';' || r == '{' || r == '}'
+
+		if start >= 0 && (isSpace(r) || delim) {
+			cmd = append(cmd, token{text: line[start:i], line: lineNum, col: first})
+			start = -1
+		}
+
+		switch {
+		case start >= 0, isSpace(r):
+		case delim:
+			endCommand()
+			if r != ';' {
+				res = append(res, []token{{text: string(r), line: lineNum, col: col}})
+			}
+		case strings.HasPrefix(line[i:], "//"), r == '#' && (len(cmd) == 0 || !isHexColorWord(line[i:])):
+			comment = line[i:]
+			break scan
+		default:
+			start, first = i, col
+		}
+	}
+	if start >= 0 {
+		cmd = append(cmd, token{text: line[start:], line: lineNum, col: first})
+	}
+	endCommand()
+
+	return res, comment
+}
+
+// isHexColorWord перевіряє, чи слово на початку рядка s є шістнадцятковим кольором, можливо з "@<зсув>".
+func isHexColorWord(s string) bool {
+	end := strings.IndexFunc(s, func(r rune) bool { return isSpace(r) || r == ';' || r == '{' || r == '}' })
+	if end >= 0 {
+		s = s[:end]
+	}
+	hex, _, _ := strings.Cut(s[1:], "@")
+	switch len(hex) {
+	case 3, 6, 8:
+	default:
+		return false
+	}
+	_, err := strconv.ParseUint(hex, 16, 32)
+	return err == nil
+}
+
+func isSpace(r rune) bool {
+	return r == ' ' || r == '\t' || r == '\r' || r == '\n'
+}
+
+// command послідовно розбирає аргументи однієї команди. Помилки, які повертають його методи, мають тип *ParseError
+// та вказують на аргумент, в якому виникла помилка.
+type command struct {
+	name   token
+	args   []token
+	pos    int    // Номер наступного аргументу
+	source string // Рядок скрипта, з якого взято команду
+
+	vars map[string]float64 // Змінні, доступні у виразах
+
+	lastExpr  string  // Текст останнього розібраного виразу
+	lastFirst int     // Номер першого аргументу останнього виразу
+	lastValue float64 // Значення останнього виразу
+}
+
+func newCommand(tokens []token, source string, vars map[string]float64) *command {
+	return &command{name: tokens[0], args: tokens[1:], source: source, vars: vars}
+}
+
+// more перевіряє, чи залишились нерозібрані аргументи.
+func (c *command) more() bool {
+	return c.pos < len(c.args)
+}
+
+// peek повертає наступний аргумент, не розбираючи його, або порожній рядок, якщо аргументів більше немає.
+func (c *command) peek() string {
+	return c.peekAt(0)
+}
+
+// peekAt повертає аргумент, що стоїть через n аргументів після наступного.
+func (c *command) peekAt(n int) string {
+	if c.pos+n < len(c.args) {
+		return c.args[c.pos+n].text
+	}
+	return ""
+}
+
+// keyword розбирає наступний аргумент, якщо він збігається з word.
+func (c *command) keyword(word string) bool {
+	if c.peek() == word {
+		c.pos++
+		return true
+	}
+	return false
+}
+
+// errorAt створює помилку, що стосується аргументу з номером arg.
+func (c *command) errorAt(arg int, message, expected string) *ParseError {
+	err := &ParseError{
+		Line:     c.name.line,
+		Column:   c.name.col,
+		Command:  c.name.text,
+		Arg:      arg,
+		Message:  message,
+		Expected: expected,
+		Source:   c.source,
+	}
+
+	switch {
+	case arg < 0:
+		err.Got = strconv.Quote(c.name.text)
+	case arg < len(c.args):
+		err.Column = c.args[arg].col
+		err.Got = strconv.Quote(c.args[arg].text)
+	default:
+		// Відсутній аргумент позначається одразу після кінця команди.
+		last := c.name
+		if len(c.args) > 0 {
+			last = c.args[len(c.args)-1]
+		}
+		err.Column = last.col + utf8.RuneCountInString(last.text) + 1
+		err.Got = "end of command"
+	}
+	return err
+}
+
+// fail повертає помилку для наступного аргументу.
+func (c *command) fail(message, expected string) *ParseError {
+	return c.errorAt(c.pos, message, expected)
+}
+
+// next повертає наступний аргумент. Якщо аргументів більше немає, повертається помилка з очікуваним значенням.
+func (c *command) next(expected string) (string, error) {
+	if !c.more() {
+		return "", c.fail("invalid params count", expected)
+	}
+	c.pos++
+	return c.args[c.pos-1].text, nil
+}
+
+// end перевіряє, що усі аргументи команди розібрані.
+func (c *command) end() error {
+	if c.more() {
+		// Слово-колір на кшталт "#abc" не починає коментар, про що варто підказати.
+		if strings.HasPrefix(c.peek(), "#") {
+			return c.fail("invalid params count", `end of command or "# <comment>"`)
+		}
+		return c.fail("invalid params count", "end of command")
+	}
+	return nil
+}
+
+// expr розбирає арифметичний вираз, що може займати кілька аргументів, та повертає його значення.
+func (c *command) expr(expected string) (float64, error) {
+	first := c.pos
+	text, err := c.next(expected)
+	if err != nil {
+		return 0, err
+	}
+	for continuesExpr(text, c.peek()) {
+		text += " " + c.args[c.pos].text
+		c.pos++
+	}
+
+	v, err := evalExpr(text, c.vars)
+	c.lastExpr, c.lastFirst, c.lastValue = text, first, v
+	if err != nil {
+		res := c.errorAt(first, "invalid expression: "+err.Error(), expected)
+		res.Got = strconv.Quote(text)
+		return 0, res
+	}
+	return v, nil
+}
+
+// exprError створює помилку для значення останнього розібраного виразу, що не задовольняє обмеження.
+func (c *command) exprError(message, expected string) *ParseError {
+	res := c.errorAt(c.lastFirst, message, expected)
+	res.Got = strconv.Quote(c.lastExpr)
+	if value := strconv.FormatFloat(c.lastValue, 'g', -1, 32); value != c.lastExpr {
+		res.Got += " = " + value
+	}
+	return res
+}
+
+// number розбирає вираз зі скінченним числовим значенням.
+func (c *command) number() (float32, error) {
+	v, err := c.expr("number")
+	if err == nil && math.Abs(v) > math.MaxFloat32 {
+		return 0, c.exprError("invalid params", "number")
+	}
+	return float32(v), err
+}
+
+// numberIn розбирає число з діапазону [min, max].
+func (c *command) numberIn(min, max float32) (float32, error) {
+	num, err := c.number()
+	if err == nil && (num < min || num > max) {
+		return 0, c.exprError("invalid params", fmt.Sprintf("number in [%g, %g]", min, max))
+	}
+	return num, err
+}
+
+// coord розбирає нормалізовану координату з діапазону [0, 1].
+func (c *command) coord() (float32, error) {
+	num, err := c.number()
+	if err == nil && (num < 0 || num > 1) {
+		return 0, c.exprError("invalid coordinates", "number in [0, 1]")
+	}
+	return num, err
+}
+
+// coords розбирає n нормалізованих координат.
+func (c *command) coords(n int) ([]float32, error) {
+	res := make([]float32, n)
+	for i := range res {
+		var err error
+		if res[i], err = c.coord(); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// positive розбирає додатне число.
+func (c *command) positive() (float32, error) {
+	num, err := c.number()
+	if err == nil && num <= 0 {
+		return 0, c.exprError("invalid params", "positive number")
+	}
+	return num, err
+}
+
+// integer розбирає вираз з цілим значенням.
+func (c *command) integer() (int, error) {
+	const expected = "integer"
+	v, err := c.expr(expected)
+	if err != nil {
+		return 0, err
+	}
+	if v != math.Trunc(v) || math.Abs(v) > math.MaxInt32 {
+		return 0, c.exprError("invalid params", expected)
+	}
+	return int(v), nil
+}
+
+// integerFrom розбирає ціле число, не менше за min.
+func (c *command) integerFrom(min int) (int, error) {
+	v, err := c.integer()
+	if err == nil && v < min {
+		return 0, c.exprError("invalid params", fmt.Sprintf("integer >= %d", min))
+	}
+	return v, err
+}
+
+// integerIn розбирає ціле число з діапазону [min, max].
+func (c *command) integerIn(min, max int) (int, error) {
+	v, err := c.integer()
+	if err == nil && (v < min || v > max) {
+		return 0, c.exprError("invalid params", fmt.Sprintf("integer in [%d, %d]", min, max))
+	}
+	return v, err
+}
+
+// id розбирає ідентифікатор фігури.
+func (c *command) id() (int, error) {
+	id, err := c.integer()
+	if err == nil && id < 1 {
+		return 0, c.exprError("invalid params", "figure id")
+	}
+	return id, err
+}
+
+// groupName розбирає назву групи.
+func (c *command) groupName() (string, error) {
+	text, err := c.next("group name")
+	if err != nil {
+		return "", err
+	}
+	if !isName(text) {
+		return "", c.errorAt(c.pos-1, "invalid params", "group name")
+	}
+	return text, nil
+}
+
+// color розбирає колір.
+func (c *command) color() (color.Color, error) {
+	const expected = "color name or #rrggbb"
+	text, err := c.next(expected)
+	if err != nil {
+		return nil, err
+	}
+	col, err := parseColor(text)
+	if err != nil {
+		return nil, c.errorAt(c.pos-1, "invalid color", expected)
+	}
+	return col, nil
+}
+
+// oneOf розбирає аргумент, що має бути одним зі слів words.
+func (c *command) oneOf(words ...string) (string, error) {
+	expected := strings.Join(words, ", ")
+	if len(words) > 1 {
+		expected = "one of " + expected
+	}
+	text, err := c.next(expected)
+	if err != nil {
+		return "", err
+	}
+	for _, w := range words {
+		if text == w {
+			return text, nil
+		}
+	}
+	return "", c.errorAt(c.pos-1, "invalid params", expected)
+}
+
+// option розбирає аргумент виду "<key>=<значення>", якщо наступний аргумент має такий вигляд, та повертає значення.
+func (c *command) option(key string) (string, bool) {
+	value, ok := strings.CutPrefix(c.peek(), key+"=")
+	if ok {
+		c.pos++
+	}
+	return value, ok
+}
+
+// seed розбирає необов'язкову опцію "seed=<n>".
+func (c *command) seed() (int64, bool, error) {
+	value, ok := c.option("seed")
+	if !ok {
+		return 0, false, nil
+	}
+	seed, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, false, c.errorAt(c.pos-1, "invalid params", "seed=<integer>")
+	}
+	return seed, true, nil
+}
@@ -0,0 +1,423 @@
+package lang
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/roman-mazur/architecture-lab-3/painter"
+)
+
+// maxSteps обмежує кількість команд та ітерацій циклів, виконаних під час розгортання одного скрипта.
+// Так скрипт з циклами не може згенерувати необмежену кількість операцій.
+const maxSteps = 100000
+
+// maxFigures обмежує кількість фігур, які додають операції одного скрипта, зокрема "random figures" у циклах.
+const maxFigures = painter.MaxFigures
+
+// statement команда скрипта або блок з заголовком та тілом, наприклад "repeat 3 { ... }".
+type statement struct {
+	tokens []token
+	source string      // Рядок скрипта, з якого взято команду
+	body   []statement // Тіло блоку
+	block  bool
+	end    int // Номер рядка, що закриває блок
+}
+
+// sourceCommand команда скрипта разом з рядком, з якого її взято.
+type sourceCommand struct {
+	tokens []token
+	source string
+}
+
+// isBlockHeader перевіряє, чи команда з назвою name має тіло у фігурних дужках.
+func isBlockHeader(name string) bool {
+	return name == "repeat" || name == "for" || name == "proc"
+}
+
+// buildStatements будує дерево блоків з послідовності команд, починаючи з lines[*pos]. Якщо nested, розбір
+// завершується на дужці '}', що закриває блок.
+func buildStatements(lines []sourceCommand, pos *int, nested bool, diags *Diagnostics) ([]statement, bool) {
+	var res []statement
+	for *pos < len(lines) {
+		l := lines[*pos]
+		*pos++
+
+		switch name := l.tokens[0]; {
+		case name.text == "}":
+			if nested {
+				return res, true
+			}
+			diags.add(tokenError(name, l.source, "unexpected '}'"))
+		case name.text == "{":
+			diags.add(tokenError(name, l.source, "block without header"))
+			buildStatements(lines, pos, true, diags)
+		case isBlockHeader(name.text):
+			st := statement{tokens: l.tokens, source: l.source, block: true}
+			if *pos >= len(lines) || lines[*pos].tokens[0].text != "{" {
+				err := tokenError(name, l.source, "missing block")
+				err.Expected, err.Got = "'{'", "end of command"
+				diags.add(err)
+				continue
+			}
+			*pos++
+
+			var closed bool
+			if st.body, closed = buildStatements(lines, pos, true, diags); !closed {
+				err := tokenError(name, l.source, "unclosed block")
+				err.Expected, err.Got = "'}'", "end of script"
+				diags.add(err)
+			} else {
+				st.end = lines[*pos-1].tokens[0].line
+			}
+			res = append(res, st)
+		default:
+			res = append(res, statement{tokens: l.tokens, source: l.source})
+		}
+	}
+	return res, false
+}
+
+// tokenError створює помилку, що стосується слова t, яке є назвою команди або дужкою блоку.
+func tokenError(t token, source, message string) *ParseError {
+	return &ParseError{Line: t.line, Column: t.col, Command: t.text, Arg: -1, Message: message, Source: source}
+}
+
+// expander розгортає дерево команд у плаский список операцій, обчислюючи вирази, виконуючи цикли та процедури.
+type expander struct {
+	vars    map[string]float64 // Змінні поточної області видимості
+	globals map[string]float64 // Змінні скрипта
+	ops     []painter.Operation
+	sites   []opSite // Місця у скрипті, з яких отримано операції ops
+	diags   Diagnostics
+	steps   int
+	figures int  // Кількість фігур, які додають операції ops
+	stop    bool // Розгортання перервано через перевищення обмежень
+
+	text    []string         // Рядки скрипта
+	procs   map[string]*Proc // Процедури, визначені у скрипті
+	library *Procs           // Спільні процедури
+	depth   int              // Вкладеність циклів та викликів
+	calls   int              // Вкладеність викликів
+
+	scripts *Library // Бібліотека скриптів для include
+	files   []string // Стек включених скриптів, починаючи з поточного
+	shared  bool     // Виконується тіло спільної процедури
+
+	// site виклик спільної процедури чи include у скрипті. Помилки з іншого тексту, ніж сам скрипт, повідомляються
+	// у місці виклику. procName назва спільної процедури, що виконується.
+	site     *statement
+	procName string
+
+	// Помилки у тілі циклу повідомляються один раз, а не на кожній ітерації.
+	reported map[reportKey]bool
+}
+
+type reportKey struct {
+	line, col int
+	message   string
+}
+
+func newExpander(text []string, p *Parser) *expander {
+	vars := map[string]float64{}
+	return &expander{
+		vars:     vars,
+		globals:  vars,
+		text:     text,
+		procs:    map[string]*Proc{},
+		library:  &p.Procs,
+		scripts:  p.Scripts,
+		reported: map[reportKey]bool{},
+	}
+}
+
+// location описує текст, команди якого зараз розгортаються.
+func (x *expander) location() string {
+	switch {
+	case x.shared:
+		return "procedure " + x.procName
+	case x.file() != "":
+		return strconv.Quote(x.file())
+	}
+	return "script"
+}
+
+// file повертає назву скрипта бібліотеки, команди якого зараз розгортаються, або порожній рядок для самого скрипта.
+func (x *expander) file() string {
+	if len(x.files) == 0 {
+		return ""
+	}
+	return x.files[len(x.files)-1]
+}
+
+// report додає повідомлення про помилку, якщо воно ще не було додане.
+func (x *expander) report(err *ParseError) {
+	if x.site != nil {
+		inner := err
+		err = tokenError(x.site.tokens[0], x.site.source, fmt.Sprintf("in %s at line %d: %s", x.location(), inner.Line, inner.Message))
+		err.Expected, err.Got = inner.Expected, inner.Got
+	}
+	key := reportKey{err.Line, err.Column, err.Message}
+	if x.reported[key] {
+		return
+	}
+	x.reported[key] = true
+	if !x.diags.add(err) {
+		x.stop = true
+	}
+}
+
+// opSite повертає місце у скрипті для операції з команди st. Операції зі спільних процедур та включених скриптів
+// відносяться до місця виклику.
+func (x *expander) opSite(st statement) opSite {
+	if x.site == nil {
+		return opSite{name: st.tokens[0], source: st.source}
+	}
+	prefix := fmt.Sprintf("in %s at line %d: ", x.location(), st.tokens[0].line)
+	return opSite{name: x.site.tokens[0], source: x.site.source, prefix: prefix}
+}
+
+// step враховує виконання однієї команди чи ітерації. Повертає false, якщо обмеження maxSteps перевищено.
+func (x *expander) step(st statement) bool {
+	if x.steps++; x.steps > maxSteps {
+		x.report(tokenError(st.tokens[0], st.source, "script expands to too many operations"))
+		x.stop = true
+	}
+	return !x.stop
+}
+
+// addFigures враховує фігури, які додасть операція op. Повертає false, якщо обмеження maxFigures перевищено.
+func (x *expander) addFigures(st statement, op painter.Operation) bool {
+	switch op := op.(type) {
+	case painter.Figure:
+		x.figures++
+	case painter.RandomFigures:
+		x.figures += op.Count
+	}
+	if x.figures > maxFigures {
+		x.report(tokenError(st.tokens[0], st.source, "script adds too many figures"))
+		x.stop = true
+	}
+	return !x.stop
+}
+
+// run розгортає послідовність команд. Повертає false, якщо у ній виникла хоча б одна помилка.
+func (x *expander) run(stmts []statement) bool {
+	ok := true
+	for _, st := range stmts {
+		if !x.step(st) {
+			return false
+		}
+
+		var (
+			c   = newCommand(st.tokens, st.source, x.vars)
+			op  painter.Operation
+			err error
+		)
+		switch {
+		case st.block:
+			err = x.block(c, st)
+		case isCall(st.tokens):
+			err = x.call(c, st)
+		case c.name.text == "include":
+			err = x.include(c, st)
+		default:
+			op, err = parseCommand(c)
+		}
+
+		if err != nil {
+			x.report(asParseError(err, c.name, st.source))
+			ok = false
+			continue
+		}
+		if op != nil {
+			if !x.addFigures(st, op) {
+				return false
+			}
+			x.ops = append(x.ops, op)
+			x.sites = append(x.sites, x.opSite(st))
+		}
+	}
+	return ok && !x.stop
+}
+
+// block розбирає заголовок блоку та виконує його тіло. Цикл зупиняється після першої ітерації з помилкою.
+// Блок процедури лише визначає процедуру.
+func (x *expander) block(c *command, st statement) error {
+	body := st.body
+	if c.name.text == "proc" {
+		if x.depth > 0 {
+			return c.errorAt(-1, "procedures can only be defined at the top level", "")
+		}
+		p, err := parseProc(c, body, x.text, st.end)
+		if err != nil {
+			return err
+		}
+		p.file = x.file()
+		x.procs[p.Name] = p
+		return nil
+	}
+
+	x.depth++
+	defer func() { x.depth-- }()
+
+	switch c.name.text {
+	case "repeat":
+		n, err := c.integerFrom(0)
+		if err == nil {
+			err = c.end()
+		}
+		if err != nil {
+			return err
+		}
+		for i := 0; i < n; i++ {
+			if !x.run(body) || !x.step(statement{tokens: []token{c.name}, source: c.source}) {
+				break
+			}
+		}
+	case "for":
+		loop, err := parseFor(c)
+		if err != nil {
+			return err
+		}
+
+		saved, hadSaved := x.vars[loop.name]
+		for i := 0; ; i++ {
+			v := loop.from + float64(i)*loop.step
+			if loop.step > 0 && v > loop.to+1e-9 || loop.step < 0 && v < loop.to-1e-9 {
+				break
+			}
+			x.vars[loop.name] = v
+			if !x.run(body) || !x.step(statement{tokens: []token{c.name}, source: c.source}) {
+				break
+			}
+		}
+		if hadSaved {
+			x.vars[loop.name] = saved
+		} else {
+			delete(x.vars, loop.name)
+		}
+	}
+	return nil
+}
+
+// forLoop параметри циклу "for <змінна> in <від>..<до> [step <крок>]". Обидві межі входять у діапазон.
+type forLoop struct {
+	name           string
+	from, to, step float64
+}
+
+func parseFor(c *command) (forLoop, error) {
+	loop := forLoop{step: 1}
+
+	var err error
+	if loop.name, err = c.next("variable name"); err != nil {
+		return loop, err
+	}
+	if !isVariable(loop.name) {
+		return loop, c.errorAt(c.pos-1, "invalid params", "variable name")
+	}
+	if _, err := c.oneOf("in"); err != nil {
+		return loop, err
+	}
+
+	// Діапазон може займати кілька слів, наприклад "0 .. n - 1", тому збирається до "step" або кінця команди.
+	first := c.pos
+	var parts []string
+	for c.more() && c.peek() != "step" {
+		text, _ := c.next("")
+		parts = append(parts, text)
+	}
+	from, to, ok := strings.Cut(strings.Join(parts, " "), "..")
+	if !ok {
+		return loop, c.errorAt(first, "invalid params", "range <from>..<to>")
+	}
+	if loop.from, err = evalExpr(from, c.vars); err == nil {
+		loop.to, err = evalExpr(to, c.vars)
+	}
+	if err != nil {
+		return loop, c.errorAt(first, "invalid expression: "+err.Error(), "range <from>..<to>")
+	}
+
+	if c.keyword("step") {
+		if loop.step, err = c.expr("number"); err != nil {
+			return loop, err
+		}
+		if math.Abs(loop.step) < 1e-6 {
+			return loop, c.exprError("invalid params", "non-zero step")
+		}
+	}
+	return loop, c.end()
+}
+
+// call виконує виклик процедури. Процедури скрипта мають перевагу над спільними процедурами з тією ж назвою.
+func (x *expander) call(c *command, st statement) error {
+	parts := []string{c.name.text}
+	for c.more() {
+		word, _ := c.next("")
+		parts = append(parts, word)
+	}
+	text := strings.Join(parts, " ")
+
+	name, args, ok := splitCall(text)
+	if !ok {
+		err := c.errorAt(-1, "invalid procedure call", "<name>(<arg>, ...)")
+		err.Got = fmt.Sprintf("%q", text)
+		return err
+	}
+
+	// Спільні процедури не бачать процедур скрипта, з якого їх викликано.
+	p, local := x.procs[name]
+	if !local || x.shared {
+		p, local = x.library.Get(name), false
+	}
+	if p == nil {
+		return c.errorAt(-1, fmt.Sprintf("undefined procedure %q", name), "")
+	}
+	if len(args) != len(p.Params) {
+		return c.errorAt(-1, "invalid params count", fmt.Sprintf("%d arguments for %s", len(p.Params), name))
+	}
+	if x.calls >= maxCallDepth {
+		return c.errorAt(-1, "procedure calls nested too deeply", "")
+	}
+
+	// Локальні змінні виклику починаються з копії глобальних змінних та параметрів.
+	vars := make(map[string]float64, len(x.globals)+len(args))
+	for k, v := range x.globals {
+		vars[k] = v
+	}
+	for i, arg := range args {
+		v, err := evalExpr(arg, x.vars)
+		if err != nil {
+			res := c.errorAt(-1, "invalid expression: "+err.Error(), "number")
+			res.Got = fmt.Sprintf("%q", strings.TrimSpace(arg))
+			return res
+		}
+		vars[p.Params[i]] = v
+	}
+
+	// Тіло процедури з іншого скрипта виконується так, ніби розгортається цей скрипт.
+	files, foreign := x.files, local && p.file != x.file()
+	if foreign {
+		x.files = append(x.files[:len(x.files):len(x.files)], p.file)
+	}
+	saved, savedShared, savedSite, savedName := x.vars, x.shared, x.site, x.procName
+	if x.site == nil && (!local || foreign) {
+		x.site = &st
+	}
+	x.vars, x.shared = vars, !local
+	if !local {
+		x.procName = name
+	}
+	x.depth++
+	x.calls++
+
+	x.run(p.body)
+
+	x.vars, x.shared, x.site, x.procName = saved, savedShared, savedSite, savedName
+	x.files = files
+	x.depth--
+	x.calls--
+	return nil
+}
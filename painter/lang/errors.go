@@ -0,0 +1,142 @@
+package lang
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Severity важливість повідомлення про скрипт.
+type Severity int
+
+const (
+	SeverityError   Severity = iota // Скрипт не може бути виконаний
+	SeverityWarning                 // Скрипт виконується, але, ймовірно, містить помилку
+)
+
+func (s Severity) String() string {
+	if s == SeverityWarning {
+		return "warning"
+	}
+	return "error"
+}
+
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// ParseError помилка розбору скрипта разом з місцем, де вона виникла.
+type ParseError struct {
+	Severity Severity `json:"severity"`
+	Line     int      `json:"line"`               // Номер рядка, починаючи з 1
+	Column   int      `json:"column"`             // Номер символу у рядку, починаючи з 1
+	Command  string   `json:"command,omitempty"`  // Назва команди, в якій виникла помилка
+	Arg      int      `json:"arg"`                // Номер аргументу команди, починаючи з 0, або -1 для самої команди
+	Expected string   `json:"expected,omitempty"` // Що очікувалось у цьому місці
+	Got      string   `json:"got,omitempty"`      // Що було отримано натомість
+	Message  string   `json:"message"`
+	Path     string   `json:"path,omitempty"` // JSON шлях до некоректного значення для команд у форматі JSON
+
+	Source string `json:"-"` // Рядок скрипта, в якому виникла помилка
+}
+
+func (e *ParseError) Error() string {
+	var b strings.Builder
+	if e.Path != "" {
+		fmt.Fprintf(&b, "%s: ", e.Path)
+	} else {
+		fmt.Fprintf(&b, "line %d, column %d: ", e.Line, e.Column)
+	}
+	if e.Severity != SeverityError {
+		fmt.Fprintf(&b, "%s: ", e.Severity)
+	}
+	if e.Command != "" {
+		fmt.Fprintf(&b, "%s: ", e.Command)
+	}
+	b.WriteString(e.Message)
+	if e.Expected != "" {
+		fmt.Fprintf(&b, ": expected %s, got %s", e.Expected, e.Got)
+	}
+	return b.String()
+}
+
+// Caret повертає рядок скрипта з позначкою ^ під місцем помилки.
+func (e *ParseError) Caret() string {
+	src := strings.ReplaceAll(e.Source, "\t", " ")
+	col := e.Column
+	if col < 1 {
+		col = 1
+	}
+	return src + "\n" + strings.Repeat(" ", col-1) + "^"
+}
+
+// maxDiagnostics обмежує кількість повідомлень про один скрипт.
+const maxDiagnostics = 100
+
+// Diagnostics список повідомлень про скрипт у порядку їх появи.
+type Diagnostics []*ParseError
+
+// Err повертає повідомлення як помилку, якщо серед них є хоча б одна помилка, або nil.
+func (d Diagnostics) Err() error {
+	for _, e := range d {
+		if e.Severity == SeverityError {
+			return d
+		}
+	}
+	return nil
+}
+
+// Warnings повертає лише попередження.
+func (d Diagnostics) Warnings() Diagnostics {
+	var res Diagnostics
+	for _, e := range d {
+		if e.Severity == SeverityWarning {
+			res = append(res, e)
+		}
+	}
+	return res
+}
+
+func (d Diagnostics) Error() string {
+	lines := make([]string, len(d))
+	for i, e := range d {
+		lines[i] = e.Error()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Unwrap дозволяє знайти окремі повідомлення через errors.As.
+func (d Diagnostics) Unwrap() []error {
+	res := make([]error, len(d))
+	for i, e := range d {
+		res[i] = e
+	}
+	return res
+}
+
+// add додає повідомлення. Повертає false, якщо повідомлень забагато і розбір варто припинити.
+func (d *Diagnostics) add(e *ParseError) bool {
+	if len(*d) >= maxDiagnostics {
+		return false
+	}
+	*d = append(*d, e)
+	if len(*d) == maxDiagnostics {
+		*d = append(*d, &ParseError{Severity: e.Severity, Line: e.Line, Column: 1, Arg: -1, Message: "too many errors", Source: e.Source})
+		return false
+	}
+	return true
+}
+
+// sort впорядковує повідомлення за місцем у скрипті. Повідомлення про перевищення maxDiagnostics лишається останнім.
+func (d Diagnostics) sort() {
+	n := len(d)
+	if n > maxDiagnostics {
+		n = maxDiagnostics
+	}
+	sort.SliceStable(d[:n], func(i, j int) bool {
+		if d[i].Line != d[j].Line {
+			return d[i].Line < d[j].Line
+		}
+		return d[i].Column < d[j].Column
+	})
+}
@@ -0,0 +1,257 @@
+package lang
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// constants іменовані константи, доступні у виразах.
+var constants = map[string]float64{
+	"pi": math.Pi,
+}
+
+// functions функції, доступні у виразах. Кути у sin та cos задаються у градусах, як і в командах.
+var functions = map[string]struct {
+	args int // Кількість аргументів або -1 для довільної ненульової кількості
+	fn   func(args []float64) float64
+}{
+	"min":   {-1, func(a []float64) float64 { return reduce(a, math.Min) }},
+	"max":   {-1, func(a []float64) float64 { return reduce(a, math.Max) }},
+	"sin":   {1, func(a []float64) float64 { return math.Sin(a[0] * math.Pi / 180) }},
+	"cos":   {1, func(a []float64) float64 { return math.Cos(a[0] * math.Pi / 180) }},
+	"abs":   {1, func(a []float64) float64 { return math.Abs(a[0]) }},
+	"sqrt":  {1, func(a []float64) float64 { return math.Sqrt(a[0]) }},
+	"floor": {1, func(a []float64) float64 { return math.Floor(a[0]) }},
+	"round": {1, func(a []float64) float64 { return math.Round(a[0]) }},
+}
+
+func reduce(args []float64, fn func(a, b float64) float64) float64 {
+	res := args[0]
+	for _, v := range args[1:] {
+		res = fn(res, v)
+	}
+	return res
+}
+
+// isOperator перевіряє, чи символ є бінарним оператором виразу.
+func isOperator(b byte) bool {
+	return strings.IndexByte("+-*/%", b) >= 0
+}
+
+// continuesExpr перевіряє, чи слово next продовжує вираз expr, записаний через пробіли.
+// Вираз продовжується, якщо у ньому не закриті дужки, якщо він закінчується оператором або комою, або якщо next
+// є окремим оператором чи починається з '*', '/', '%', ')' або ','. Тому "x - 1" є одним виразом, а "x -1" — двома.
+func continuesExpr(expr, next string) bool {
+	if next == "" {
+		return false
+	}
+	if strings.Count(expr, "(") > strings.Count(expr, ")") {
+		return true
+	}
+	if last := expr[len(expr)-1]; isOperator(last) || last == ',' || last == '(' {
+		return true
+	}
+	return next == "+" || next == "-" || strings.IndexByte("*/%),", next[0]) >= 0
+}
+
+// isVariable перевіряє, чи рядок може бути назвою змінної: літера або '_', за якими йдуть літери, цифри або '_'.
+func isVariable(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if !isIdentChar(s[i]) || i == 0 && s[i] >= '0' && s[i] <= '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+func isIdentChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_'
+}
+
+// evalExpr обчислює арифметичний вираз. Імена у виразі шукаються спочатку серед змінних vars, потім серед констант.
+func evalExpr(expr string, vars map[string]float64) (float64, error) {
+	e := exprParser{src: expr, vars: vars}
+	v, err := e.sum()
+	if err != nil {
+		return 0, err
+	}
+	if e.skipSpaces(); e.pos < len(e.src) {
+		return 0, fmt.Errorf("unexpected %q", e.src[e.pos:])
+	}
+	if math.IsInf(v, 0) || math.IsNaN(v) {
+		return 0, errors.New("result is not a finite number")
+	}
+	return v, nil
+}
+
+// exprParser розбирає вираз методом рекурсивного спуску, одразу обчислюючи його значення.
+type exprParser struct {
+	src  string
+	pos  int
+	vars map[string]float64
+}
+
+func (e *exprParser) skipSpaces() {
+	for e.pos < len(e.src) && e.src[e.pos] == ' ' {
+		e.pos++
+	}
+}
+
+// peek повертає наступний значущий символ виразу або 0 в кінці виразу.
+func (e *exprParser) peek() byte {
+	if e.skipSpaces(); e.pos < len(e.src) {
+		return e.src[e.pos]
+	}
+	return 0
+}
+
+// sum розбирає додавання та віднімання.
+func (e *exprParser) sum() (float64, error) {
+	v, err := e.product()
+	for err == nil {
+		op := e.peek()
+		if op != '+' && op != '-' {
+			break
+		}
+		e.pos++
+		var rhs float64
+		if rhs, err = e.product(); op == '+' {
+			v += rhs
+		} else {
+			v -= rhs
+		}
+	}
+	return v, err
+}
+
+// product розбирає множення, ділення та остачу від ділення.
+func (e *exprParser) product() (float64, error) {
+	v, err := e.unary()
+	for err == nil {
+		op := e.peek()
+		if op != '*' && op != '/' && op != '%' {
+			break
+		}
+		e.pos++
+		var rhs float64
+		if rhs, err = e.unary(); err != nil {
+			break
+		}
+		switch {
+		case op == '*':
+			v *= rhs
+		case rhs == 0:
+			err = errors.New("division by zero")
+		case op == '/':
+			v /= rhs
+		default:
+			v = math.Mod(v, rhs)
+		}
+	}
+	return v, err
+}
+
+// unary розбирає унарний мінус чи плюс.
+func (e *exprParser) unary() (float64, error) {
+	switch e.peek() {
+	case '-':
+		e.pos++
+		v, err := e.unary()
+		return -v, err
+	case '+':
+		e.pos++
+		return e.unary()
+	}
+	return e.primary()
+}
+
+// primary розбирає число, ім'я, виклик функції або вираз у дужках.
+func (e *exprParser) primary() (float64, error) {
+	c := e.peek()
+	switch {
+	case c == '(':
+		e.pos++
+		v, err := e.sum()
+		if err != nil {
+			return 0, err
+		}
+		if e.peek() != ')' {
+			return 0, errors.New("missing ')'")
+		}
+		e.pos++
+		return v, nil
+	case c >= '0' && c <= '9' || c == '.':
+		return e.number()
+	case isIdentChar(c) && !(c >= '0' && c <= '9'):
+		return e.name()
+	case c == 0:
+		return 0, errors.New("unexpected end of expression")
+	}
+	return 0, fmt.Errorf("unexpected %q", e.src[e.pos:])
+}
+
+func (e *exprParser) number() (float64, error) {
+	start := e.pos
+	for e.pos < len(e.src) {
+		c := e.src[e.pos]
+		exp := c == 'e' || c == 'E'
+		sign := (c == '+' || c == '-') && e.pos > start && (e.src[e.pos-1] == 'e' || e.src[e.pos-1] == 'E')
+		if !(c >= '0' && c <= '9' || c == '.' || exp || sign) {
+			break
+		}
+		e.pos++
+	}
+	v, err := strconv.ParseFloat(e.src[start:e.pos], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q", e.src[start:e.pos])
+	}
+	return v, nil
+}
+
+func (e *exprParser) name() (float64, error) {
+	start := e.pos
+	for e.pos < len(e.src) && isIdentChar(e.src[e.pos]) {
+		e.pos++
+	}
+	name := e.src[start:e.pos]
+
+	if e.peek() != '(' {
+		if v, ok := e.vars[name]; ok {
+			return v, nil
+		}
+		if v, ok := constants[name]; ok {
+			return v, nil
+		}
+		return 0, fmt.Errorf("undefined variable %q", name)
+	}
+
+	f, ok := functions[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown function %q", name)
+	}
+	e.pos++
+
+	var args []float64
+	for e.peek() != ')' {
+		if len(args) > 0 {
+			if e.peek() != ',' {
+				return 0, errors.New("missing ')'")
+			}
+			e.pos++
+		}
+		v, err := e.sum()
+		if err != nil {
+			return 0, err
+		}
+		args = append(args, v)
+	}
+	e.pos++
+
+	if f.args < 0 && len(args) == 0 || f.args >= 0 && len(args) != f.args {
+		return 0, fmt.Errorf("wrong number of arguments for %s", name)
+	}
+	return f.fn(args), nil
+}
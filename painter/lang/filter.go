@@ -0,0 +1,107 @@
+package lang
+
+import (
+	"bufio"
+	"io"
+
+	"github.com/roman-mazur/architecture-lab-3/painter"
+)
+
+// parseFilterCommand розбирає команди "filter <фільтр>" та "filter clear".
+func parseFilterCommand(c *command) (painter.Operation, error) {
+	if c.keyword("clear") {
+		return painter.SetFilters{}, nil
+	}
+
+	if _, err := c.next("filter"); err != nil {
+		return nil, err
+	}
+	f, err := parseFilter(c)
+	if err != nil {
+		return nil, err
+	}
+	return painter.AddFilter{Filter: f}, nil
+}
+
+// parseFilter розбирає опис фільтра: "grayscale", "invert", "blur <радіус>", "pixelate <розмір>",
+// "brightness <-1..1>", "contrast <множник>" або "colorblind <protanopia | deuteranopia | tritanopia>".
+// Назва фільтра має бути останнім розібраним словом c.
+func parseFilter(c *command) (painter.Filter, error) {
+	nameArg := c.pos - 1
+	name := c.name.text
+	if nameArg >= 0 {
+		name = c.args[nameArg].text
+	}
+
+	switch name {
+	case "grayscale":
+		return painter.Grayscale{}, nil
+	case "invert":
+		return painter.Invert{}, nil
+	case "blur", "pixelate":
+		n, err := c.integerFrom(1)
+		if err != nil {
+			return nil, err
+		}
+		if name == "blur" {
+			return painter.Blur{Radius: n}, nil
+		}
+		return painter.Pixelate{Size: n}, nil
+	case "brightness":
+		amount, err := c.numberIn(-1, 1)
+		if err != nil {
+			return nil, err
+		}
+		return painter.Brightness{Amount: amount}, nil
+	case "contrast":
+		factor, err := c.number()
+		if err != nil {
+			return nil, err
+		}
+		if factor < 0 {
+			return nil, c.exprError("invalid params", "non-negative number")
+		}
+		return painter.Contrast{Factor: factor}, nil
+	case "colorblind":
+		kind, err := c.oneOf("protanopia", "deuteranopia", "tritanopia")
+		if err != nil {
+			return nil, err
+		}
+		return painter.NewColorBlind(kind)
+	default:
+		return nil, c.errorAt(nameArg, "unknown filter", "one of grayscale, invert, blur, pixelate, brightness, contrast, colorblind")
+	}
+}
+
+// ParseFilters читає ланцюжок фільтрів, по одному опису фільтра на рядок або через ';'.
+// Порожні рядки та коментарі пропускаються. Помилкою є Diagnostics з усіма некоректними описами.
+func ParseFilters(in io.Reader) ([]painter.Filter, error) {
+	var (
+		res   []painter.Filter
+		diags Diagnostics
+	)
+	scanner := bufio.NewScanner(in)
+
+	lineNum := 1
+	for ; scanner.Scan(); lineNum++ {
+		for _, tokens := range splitCommands(scanner.Text(), lineNum) {
+			c := newCommand(tokens, scanner.Text(), nil)
+			f, err := parseFilter(c)
+			if err == nil {
+				err = c.end()
+			}
+			if err != nil {
+				if !diags.add(asParseError(err, tokens[0], scanner.Text())) {
+					return res, diags
+				}
+				continue
+			}
+			res = append(res, f)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		diags.add(&ParseError{Line: lineNum, Column: 1, Arg: -1, Message: err.Error()})
+	}
+	return res, diags.Err()
+}
@@ -0,0 +1,454 @@
+package lang
+
+import (
+	"bufio"
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/roman-mazur/architecture-lab-3/painter"
+)
+
+// Format записує список операцій як скрипт у канонічному вигляді, по одній команді на рядок.
+// Для операцій, отриманих з Parser, розбір результату дає той самий список операцій.
+// Операції, які неможливо записати командами скрипта, наприклад фігура з візерунком, є помилкою.
+func Format(ops []painter.Operation) (string, error) {
+	var b strings.Builder
+	for i, op := range ops {
+		line, err := formatOp(op)
+		if err != nil {
+			return "", fmt.Errorf("operation %d: %w", i, err)
+		}
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+	return b.String(), nil
+}
+
+func formatOp(op painter.Operation) (string, error) {
+	switch op := op.(type) {
+	case painter.Update:
+		return "update", nil
+	case painter.Reset:
+		return "reset", nil
+	case painter.Fill:
+		switch {
+		case op.Color == namedColors["white"]:
+			return "white", nil
+		case op.Color == namedColors["green"]:
+			return "green", nil
+		}
+		return "fill " + formatColor(op.Color), nil
+	case painter.BgRect:
+		return "bgrect " + nums(op.X1, op.Y1, op.X2, op.Y2), nil
+	case painter.Figure:
+		return formatFigure("figure", op)
+	case painter.Move:
+		return "move " + nums(op.X, op.Y), nil
+	case painter.Group:
+		return "group " + op.Name + " " + strings.Join(op.Members, " "), nil
+	case painter.GroupMove:
+		return fmt.Sprintf("move group %s %s", op.Name, nums(op.X, op.Y)), nil
+	case painter.GroupScale:
+		return fmt.Sprintf("scale group %s %s", op.Name, num(op.Factor)), nil
+	case painter.GroupColor:
+		return fmt.Sprintf("recolor group %s %s", op.Name, formatColor(op.Color)), nil
+	case painter.GroupDelete:
+		return "delete group " + op.Name, nil
+	case painter.DeleteFigure:
+		return fmt.Sprintf("delete figure %d", op.ID), nil
+	case painter.DeleteIndex:
+		return fmt.Sprintf("delete index %d", op.Index), nil
+	case painter.DeleteAt:
+		return "delete at " + nums(op.X, op.Y), nil
+	case painter.DeleteRect:
+		return "delete rect", nil
+	case painter.ReplaceFigure:
+		return formatFigure(fmt.Sprintf("replace figure %d", op.ID), op.Figure)
+	case painter.ReplaceRect:
+		return "replace rect " + nums(op.Rect.X1, op.Rect.Y1, op.Rect.X2, op.Rect.Y2), nil
+	case painter.LayoutGrid:
+		res := fmt.Sprintf("layout grid %d %d", op.Rows, op.Cols)
+		if op.Margin != 0 {
+			res += " " + num(op.Margin)
+		}
+		return res + groupSuffix(op.Group), nil
+	case painter.LayoutLine:
+		return "layout line " + nums(op.X1, op.Y1, op.X2, op.Y2) + groupSuffix(op.Group), nil
+	case painter.LayoutCircle:
+		return "layout circle " + nums(op.X, op.Y, op.R) + groupSuffix(op.Group), nil
+	case painter.Velocity:
+		return "velocity " + formatTarget(target{id: op.ID, group: op.Group}) + nums(op.VX, op.VY), nil
+	case painter.Acceleration:
+		return "accel " + formatTarget(target{id: op.ID, group: op.Group}) + nums(op.AX, op.AY), nil
+	case painter.RandomVelocity:
+		return "velocity random " + num(op.Max) + seedSuffix(op.Seed), nil
+	case painter.Simulate:
+		switch {
+		case !op.Enabled:
+			return "simulate off", nil
+		case op.Collisions:
+			return "simulate on collide", nil
+		}
+		return "simulate on", nil
+	case painter.PatternFill:
+		return "fill " + formatPattern(op.Pattern), nil
+	case painter.RectFill:
+		return "fill rect " + formatPattern(op.Pattern), nil
+	case painter.FigureFill:
+		return "fill " + formatTarget(target{id: op.ID, group: op.Group}) + formatPattern(op.Pattern), nil
+	case painter.AddFilter:
+		return "filter " + op.Filter.String(), nil
+	case painter.SetFilters:
+		lines := []string{"filter clear"}
+		for _, f := range op.Filters {
+			lines = append(lines, "filter "+f.String())
+		}
+		return strings.Join(lines, "\n"), nil
+	case painter.PushClip:
+		return "clip " + op.Clip.String(), nil
+	case painter.PopClip:
+		if op.All {
+			return "unclip all", nil
+		}
+		return "unclip", nil
+	case painter.SetStroke:
+		res := "stroke " + formatTarget(target{rect: op.Rect, id: op.ID, group: op.Group})
+		if op.Stroke == nil {
+			return res + "none", nil
+		}
+		return res + formatStroke(op.Stroke), nil
+	case painter.SetHollow:
+		name := "solid "
+		if op.Hollow {
+			name = "hollow "
+		}
+		return strings.TrimSpace(name + formatTarget(target{rect: op.Rect, id: op.ID, group: op.Group})), nil
+	case painter.SetShadow:
+		res := "shadow " + formatTarget(target{rect: op.Rect, id: op.ID, group: op.Group})
+		if op.Shadow == nil {
+			return res + "none", nil
+		}
+		return res + fmt.Sprintf("%d %d %d %s", op.Shadow.DX, op.Shadow.DY, op.Shadow.Blur, formatColor(op.Shadow.Color)), nil
+	case painter.SetGlow:
+		res := "glow " + formatTarget(target{rect: op.Rect, id: op.ID, group: op.Group})
+		if op.Glow == nil {
+			return res + "none", nil
+		}
+		return res + fmt.Sprintf("%d %s", op.Glow.Radius, formatColor(op.Glow.Color)), nil
+	case painter.RandomFigures:
+		res := fmt.Sprintf("random figures %d", op.Count) + areaSuffix(op.Area)
+		if op.Colors {
+			res += " colors"
+		}
+		return res + seedSuffix(op.Seed), nil
+	case painter.RandomRect:
+		return "random rect" + areaSuffix(op.Area) + seedSuffix(op.Seed), nil
+	case painter.RandomColors:
+		return "random colors" + groupSuffix(op.Group) + seedSuffix(op.Seed), nil
+	case painter.Scatter:
+		return "scatter" + groupSuffix(op.Group) + areaSuffix(op.Area) + seedSuffix(op.Seed), nil
+	case painter.Jitter:
+		return "jitter " + num(op.Amount) + groupSuffix(op.Group) + seedSuffix(op.Seed), nil
+	}
+	return "", fmt.Errorf("%T cannot be written as a script", op)
+}
+
+// FormatScene записує скрипт, що відтворює сцену з порожнього стану: тло, прямокутник, фігури з їх
+// ідентифікаторами та оформленням, групи, відсікання та фільтри. Рух фігур не є частиною сцени і не записується.
+func FormatScene(scene painter.Scene) string {
+	lines := []string{"reset"}
+	if scene.Background != "" {
+		lines = append(lines, "fill "+scene.Background)
+	}
+	if scene.BackgroundFill != "" {
+		lines = append(lines, "fill "+scene.BackgroundFill)
+	}
+
+	// Прямокутник та фігури відсікаються стеком, що діяв на момент їх додавання.
+	var clips []string
+	setClips := func(want []string) {
+		n := 0
+		for n < len(clips) && n < len(want) && clips[n] == want[n] {
+			n++
+		}
+		for i := n; i < len(clips); i++ {
+			lines = append(lines, "unclip")
+		}
+		for _, c := range want[n:] {
+			lines = append(lines, "clip "+c)
+		}
+		clips = want
+	}
+
+	if r := scene.Rect; r != nil {
+		setClips(r.Clips)
+		lines = append(lines, "bgrect "+nums(r.X1, r.Y1, r.X2, r.Y2))
+		lines = appendStyle(lines, "rect", r.Fill, r.Stroke, r.Hollow, r.Shadow, r.Glow)
+	}
+
+	// Фігури отримують ідентифікатори по порядку, тому пропущені ідентифікатори займають тимчасові фігури.
+	nextID := 1
+	for _, f := range scene.Figures {
+		for ; nextID < f.ID; nextID++ {
+			lines = append(lines, "figure 0.5 0.5", fmt.Sprintf("delete figure %d", nextID))
+		}
+		nextID++
+
+		setClips(f.Clips)
+		line := "figure " + nums(f.X, f.Y)
+		if f.Scale != 1 {
+			line += " scale=" + num(f.Scale)
+		}
+		if f.Color != "" {
+			line += " color=" + f.Color
+		}
+		lines = append(lines, line)
+		lines = appendStyle(lines, fmt.Sprintf("figure %d", f.ID), f.Fill, f.Stroke, f.Hollow, f.Shadow, f.Glow)
+	}
+
+	for _, g := range scene.Groups {
+		if len(g.Members) > 0 {
+			lines = append(lines, "group "+g.Name+" "+strings.Join(g.Members, " "))
+		}
+	}
+	setClips(scene.Clips)
+	lines = append(lines, "filter clear")
+	for _, f := range scene.Filters {
+		lines = append(lines, "filter "+f)
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// formatFigure записує параметри фігури fig після команди cmd.
+func formatFigure(cmd string, fig painter.Figure) (string, error) {
+	if fig.Pattern != nil || fig.Stroke != nil || fig.Hollow || fig.Shadow != nil || fig.Glow != nil {
+		return "", fmt.Errorf("figure style cannot be written as a script")
+	}
+	res := cmd + " " + nums(fig.X, fig.Y)
+	if fig.Scale != 0 {
+		res += " scale=" + num(fig.Scale)
+	}
+	if fig.Color != nil {
+		res += " color=" + formatColor(fig.Color)
+	}
+	return res, nil
+}
+
+// appendStyle додає команди оформлення цілі tg, записаного у синтаксисі скриптів.
+func appendStyle(lines []string, tg, fill, stroke string, hollow bool, shadow, glow string) []string {
+	if fill != "" {
+		lines = append(lines, "fill "+tg+" "+fill)
+	}
+	if stroke != "" {
+		lines = append(lines, "stroke "+tg+" "+stroke)
+	}
+	if hollow {
+		lines = append(lines, "hollow "+tg)
+	}
+	if shadow != "" {
+		lines = append(lines, "shadow "+tg+" "+shadow)
+	}
+	if glow != "" {
+		lines = append(lines, "glow "+tg+" "+glow)
+	}
+	return lines
+}
+
+// num записує число найкоротшим рядком, з якого розбирається те саме значення float32.
+func num(v float32) string {
+	return strconv.FormatFloat(float64(v), 'g', -1, 32)
+}
+
+func nums(values ...float32) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = num(v)
+	}
+	return strings.Join(parts, " ")
+}
+
+// formatColor записує колір назвою, якщо Parser отримує його з назви, або у шістнадцятковому форматі.
+func formatColor(c color.Color) string {
+	for _, name := range []string{"white", "black", "green", "red", "blue", "yellow"} {
+		if c == namedColors[name] {
+			return name
+		}
+	}
+	return painter.HexColor(c)
+}
+
+// formatTarget записує ціль команди оформлення разом з пробілом після неї або порожній рядок для усіх фігур.
+func formatTarget(tg target) string {
+	switch {
+	case tg.rect:
+		return "rect "
+	case tg.id > 0:
+		return fmt.Sprintf("figure %d ", tg.id)
+	case tg.group != "":
+		return "group " + tg.group + " "
+	}
+	return ""
+}
+
+func formatPattern(p painter.Pattern) string {
+	switch p := p.(type) {
+	case *painter.LinearGradient:
+		return "linear " + num(p.Angle) + " " + formatStops(p.Stops)
+	case *painter.RadialGradient:
+		return "radial " + nums(p.CX, p.CY) + " " + formatStops(p.Stops)
+	case *painter.Checkerboard:
+		return fmt.Sprintf("checker %s %s %s", num(p.Size), formatColor(p.A), formatColor(p.B))
+	case *painter.Stripes:
+		return fmt.Sprintf("stripes %s %s %s", nums(p.Width, p.Angle), formatColor(p.A), formatColor(p.B))
+	}
+	return p.String()
+}
+
+func formatStops(stops []painter.Stop) string {
+	parts := make([]string, len(stops))
+	for i, s := range stops {
+		parts[i] = formatColor(s.Color) + "@" + num(s.Offset)
+	}
+	return strings.Join(parts, " ")
+}
+
+func formatStroke(s *painter.Stroke) string {
+	res := fmt.Sprintf("%s %d", formatColor(s.Color), s.Width)
+	if len(s.Dash) > 0 {
+		parts := make([]string, len(s.Dash))
+		for i, d := range s.Dash {
+			parts[i] = strconv.Itoa(d)
+		}
+		res += " dash=" + strings.Join(parts, ",")
+	}
+	return res
+}
+
+func groupSuffix(group string) string {
+	if group == "" {
+		return ""
+	}
+	return " group " + group
+}
+
+func areaSuffix(a painter.Area) string {
+	if a == painter.FullArea {
+		return ""
+	}
+	return " in " + nums(a.X1, a.Y1, a.X2, a.Y2)
+}
+
+func seedSuffix(seed int64) string {
+	if seed == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" seed=%d", seed)
+}
+
+// FormatScript приводить текст скрипта до канонічного вигляду, не змінюючи його змісту: по одній команді на рядок,
+// слова розділені одним пробілом, тіла блоків з відступом табуляцією, числа записані найкоротшим рядком, коментарі
+// починаються з "# ". Кілька порожніх рядків поспіль замінюються одним. Скрипт не обов'язково має бути коректним.
+func FormatScript(in io.Reader) (string, error) {
+	var (
+		out    []string
+		depth  int
+		blank  bool
+		header = -1 // Рядок out із заголовком блоку, до якого можна дописати '{'
+	)
+	emit := func(line string) {
+		if blank && len(out) > 0 {
+			out = append(out, "")
+		}
+		blank = false
+		out = append(out, strings.Repeat("\t", depth)+line)
+	}
+
+	scanner := bufio.NewScanner(in)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		cmds, comment := splitLine(scanner.Text(), lineNum)
+		if len(cmds) == 0 && comment == "" {
+			blank, header = true, -1
+			continue
+		}
+
+		for _, tokens := range cmds {
+			switch text := formatTokens(tokens); text {
+			case "{":
+				if header >= 0 && header == len(out)-1 {
+					out[header] += " {"
+				} else {
+					emit(text)
+				}
+				depth++
+				header = -1
+			case "}":
+				if depth > 0 {
+					depth--
+				}
+				emit(text)
+				header = -1
+			default:
+				emit(text)
+				header = -1
+				if isBlockHeader(tokens[0].text) {
+					header = len(out) - 1
+				}
+			}
+		}
+
+		if comment != "" {
+			if strings.HasPrefix(comment, "//") {
+				comment = strings.TrimPrefix(comment, "//")
+			} else {
+				comment = strings.TrimPrefix(comment, "#")
+			}
+			comment = "# " + strings.TrimSpace(comment)
+			if len(cmds) > 0 {
+				out[len(out)-1] += " " + strings.TrimSpace(comment)
+			} else {
+				emit(strings.TrimSpace(comment))
+			}
+			// '{' після коментаря опинився б всередині нього.
+			header = -1
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	if len(out) == 0 {
+		return "", nil
+	}
+	return strings.Join(out, "\n") + "\n", nil
+}
+
+// formatTokens з'єднує слова команди одним пробілом, записуючи числа у канонічному вигляді.
+func formatTokens(tokens []token) string {
+	parts := make([]string, len(tokens))
+	for i, t := range tokens {
+		parts[i] = formatNumber(t.text)
+	}
+	return strings.Join(parts, " ")
+}
+
+// formatNumber записує слово, що є числом, найкоротшим рядком з тим самим значенням. Інші слова не змінюються.
+func formatNumber(word string) string {
+	digits := strings.TrimLeft(word, "+-")
+	if digits == "" || !(digits[0] >= '0' && digits[0] <= '9' || digits[0] == '.') {
+		return word
+	}
+	if strings.Trim(digits, "0123456789.eE+-") != "" {
+		return word
+	}
+	v, err := strconv.ParseFloat(word, 64)
+	if err != nil || math.IsInf(v, 0) {
+		return word
+	}
+	if v == math.Trunc(v) && math.Abs(v) < 1e15 {
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -1,31 +1,447 @@
 package lang
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image/png"
 	"io"
 	"log"
+	"mime"
 	"net/http"
+	"os"
+	"strconv"
 	"strings"
 
 	"github.com/roman-mazur/architecture-lab-3/painter"
 )
 
 // HttpHandler конструює обробник HTTP запитів, який дані з запиту віддає у Parser, а потім відправляє отриманий список
-// операцій у painter.Loop.
+// операцій у painter.Loop. З параметром dryRun=1 скрипт лише перевіряється на копії сцени.
+// Тіло з Content-Type: application/json розбирається як масив JSON команд, дивись Parser.ParseJSON та CommandSchema.
+// Тіло, більше за maxScriptSize, відхиляється з кодом 413.
 func HttpHandler(loop *painter.Loop, p *Parser) http.Handler {
 	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
-		var in io.Reader = r.Body
-		if r.Method == http.MethodGet {
-			in = strings.NewReader(r.URL.Query().Get("cmd"))
+		var (
+			cmds  []painter.Operation
+			diags Diagnostics
+		)
+		var body []byte
+		if r.Method != http.MethodGet {
+			var ok bool
+			if body, ok = readBody(rw, r); !ok {
+				return
+			}
 		}
 
-		cmds, err := p.Parse(in)
-		if err != nil {
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		switch {
+		case r.Method == http.MethodGet:
+			cmds, diags = p.ParseDiagnostics(strings.NewReader(r.URL.Query().Get("cmd")))
+		case mediaType == "application/json":
+			cmds, diags = p.ParseJSONDiagnostics(bytes.NewReader(body))
+		default:
+			cmds, diags = p.ParseDiagnostics(bytes.NewReader(body))
+		}
+		if err := diags.Err(); err != nil {
 			log.Printf("Bad script: %s", err)
-			rw.WriteHeader(http.StatusBadRequest)
+			writeParseError(rw, err)
+			return
+		}
+
+		postOperations(rw, r, loop, cmds, diags.Warnings())
+	})
+}
+
+// SchemaHandler конструює обробник HTTP запитів, що повертає JSON Schema команд у форматі JSON.
+func SchemaHandler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			rw.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		rw.Header().Set("Content-Type", "application/schema+json")
+		if err := json.NewEncoder(rw).Encode(CommandSchema()); err != nil {
+			log.Printf("Error writing schema: %s", err)
+		}
+	})
+}
+
+// postOperations перевіряє операції на порушення меж та, якщо політика меж дозволяє, відправляє їх у цикл,
+// попередньо вилучивши операції без видимого результату.
+// Попередження скрипта warnings повертаються у тілі відповіді. З параметром запиту dryRun=1 операції лише
+// застосовуються до копії сцени, дивись writeDryRun.
+func postOperations(rw http.ResponseWriter, r *http.Request, loop *painter.Loop, cmds []painter.Operation, warnings Diagnostics) {
+	if v := r.URL.Query().Get("dryRun"); v != "" {
+		dryRun, err := strconv.ParseBool(v)
+		if err != nil {
+			writeJSON(rw, http.StatusBadRequest, errorResponse{Error: "invalid dryRun: " + v})
+			return
+		}
+		if dryRun {
+			writeDryRun(rw, r, loop, cmds, warnings)
+			return
+		}
+	}
+
+	violations, accepted := loop.PostChecked(cmds)
+	if !accepted {
+		writeJSON(rw, http.StatusUnprocessableEntity, postResponse{Error: "figures out of bounds", Violations: violations, Warnings: warnings})
+		return
+	}
+
+	if len(violations) > 0 || len(warnings) > 0 {
+		writeJSON(rw, http.StatusOK, postResponse{Violations: violations, Warnings: warnings})
+		return
+	}
+	rw.WriteHeader(http.StatusOK)
+}
+
+// writeDryRun застосовує операції до копії сцени та відповідає так само, як на звичайний запит, додаючи сцену,
+// що вийшла б в результаті. З параметром запиту preview=1 відповідь також містить кадр цієї сцени у форматі PNG.
+// Цикл подій при цьому не отримує жодних операцій.
+func writeDryRun(rw http.ResponseWriter, r *http.Request, loop *painter.Loop, cmds []painter.Operation, warnings Diagnostics) {
+	result := loop.DryRun(cmds)
+	res := dryRunResponse{postResponse: postResponse{Violations: result.Violations, Warnings: warnings}}
+	if len(result.Violations) > 0 && loop.Bounds == painter.BoundsReject {
+		res.Error = "figures out of bounds"
+		writeJSON(rw, http.StatusUnprocessableEntity, res)
+		return
+	}
+
+	scene := result.Scene()
+	res.Scene = &scene
+	if preview, _ := strconv.ParseBool(r.URL.Query().Get("preview")); preview {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, result.Image()); err != nil {
+			writeJSON(rw, http.StatusInternalServerError, errorResponse{Error: err.Error()})
+			return
+		}
+		res.Preview = buf.Bytes()
+	}
+	writeJSON(rw, http.StatusOK, res)
+}
+
+// maxScriptSize обмежує розмір скрипта у тілі запиту, зокрема скрипта, який завантажується у бібліотеку.
+const maxScriptSize = 1 << 20
+
+// readBody читає тіло запиту розміром не більше maxScriptSize. Якщо тіло не вдалося прочитати, відповідає з
+// кодом 413 та повертає false.
+func readBody(rw http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	data, err := io.ReadAll(http.MaxBytesReader(rw, r.Body, maxScriptSize))
+	if err != nil {
+		writeJSON(rw, http.StatusRequestEntityTooLarge, errorResponse{Error: err.Error()})
+		return nil, false
+	}
+	return data, true
+}
+
+// ScriptsHandler конструює обробник HTTP запитів для бібліотеки скриптів парсера.
+// GET /scripts повертає назви скриптів, GET /scripts/<назва> повертає текст скрипта, PUT /scripts/<назва> зберігає
+// скрипт з тіла запиту, а POST /scripts/<назва>/run виконує скрипт. Тіло запиту на виконання може містити
+// JSON об'єкт з числовими параметрами, які стають змінними скрипта.
+func ScriptsHandler(loop *painter.Loop, p *Parser) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/scripts"), "/")
+		name, action, _ := strings.Cut(path, "/")
+
+		switch {
+		case name == "" && r.Method == http.MethodGet:
+			names, err := p.Scripts.List()
+			if err != nil {
+				writeLibraryError(rw, err)
+				return
+			}
+			writeJSON(rw, http.StatusOK, names)
+		case action == "" && r.Method == http.MethodGet:
+			data, err := p.Scripts.Read(name)
+			if err != nil {
+				writeLibraryError(rw, err)
+				return
+			}
+			rw.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			_, _ = rw.Write(data)
+		case action == "" && r.Method == http.MethodPut:
+			data, ok := readBody(rw, r)
+			if !ok {
+				return
+			}
+			if err := p.Scripts.Write(name, data); err != nil {
+				writeLibraryError(rw, err)
+				return
+			}
+			rw.WriteHeader(http.StatusOK)
+		case action == "run" && r.Method == http.MethodPost:
+			body, ok := readBody(rw, r)
+			if !ok {
+				return
+			}
+			var params map[string]float64
+			if err := json.NewDecoder(bytes.NewReader(body)).Decode(&params); err != nil && err != io.EOF {
+				writeJSON(rw, http.StatusBadRequest, errorResponse{Error: "invalid params: " + err.Error()})
+				return
+			}
+
+			cmds, diags, err := p.scriptDiagnostics(name, params)
+			switch {
+			case err != nil:
+				writeLibraryError(rw, err)
+			case diags.Err() != nil:
+				writeParseError(rw, diags)
+			default:
+				postOperations(rw, r, loop, cmds, diags.Warnings())
+			}
+		case name != "" && (action == "" || action == "run"):
+			rw.WriteHeader(http.StatusMethodNotAllowed)
+		default:
+			writeJSON(rw, http.StatusNotFound, errorResponse{Error: "not found"})
+		}
+	})
+}
+
+// templateRequest тіло запиту до TemplateHandler. Шаблон задається текстом Template або назвою Script
+// скрипта бібліотеки.
+type templateRequest struct {
+	Template string         `json:"template"`
+	Script   string         `json:"script"`
+	Params   map[string]any `json:"params"`
+}
+
+// TemplateHandler конструює обробник HTTP запитів, який підставляє JSON параметри у шаблон скрипта,
+// розбирає отриманий скрипт та відправляє операції у painter.Loop. Дивись RenderTemplate.
+func TemplateHandler(loop *painter.Loop, p *Parser) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			rw.WriteHeader(http.StatusMethodNotAllowed)
 			return
 		}
 
-		loop.Post(painter.OperationList(cmds))
+		var req templateRequest
+		if err := json.NewDecoder(http.MaxBytesReader(rw, r.Body, maxScriptSize)).Decode(&req); err != nil {
+			writeJSON(rw, http.StatusBadRequest, errorResponse{Error: "invalid request: " + err.Error()})
+			return
+		}
+		if (req.Template == "") == (req.Script == "") {
+			writeJSON(rw, http.StatusBadRequest, errorResponse{Error: "exactly one of template and script is required"})
+			return
+		}
+
+		src := req.Template
+		if req.Script != "" {
+			data, err := p.Scripts.Read(req.Script)
+			if err != nil {
+				writeLibraryError(rw, err)
+				return
+			}
+			src = string(data)
+		}
+
+		script, err := RenderTemplate(src, req.Params)
+		if err != nil {
+			log.Printf("Bad template: %s", err)
+			writeParseError(rw, err)
+			return
+		}
+		cmds, diags := p.ParseDiagnostics(strings.NewReader(script))
+		if err := diags.Err(); err != nil {
+			log.Printf("Bad template: %s", err)
+			writeParseError(rw, err)
+			return
+		}
+		postOperations(rw, r, loop, cmds, diags.Warnings())
+	})
+}
+
+// writeLibraryError відповідає клієнту на помилку бібліотеки скриптів.
+func writeLibraryError(rw http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		status, err = http.StatusNotFound, errors.New("unknown script")
+	case errors.Is(err, ErrNoLibrary):
+		status = http.StatusNotFound
+	case errors.Is(err, ErrInvalidName):
+		status = http.StatusBadRequest
+	}
+	writeJSON(rw, status, errorResponse{Error: err.Error()})
+}
+
+// SceneHandler конструює обробник HTTP запитів, який повертає поточний стан сцени у форматі JSON, або, з параметром
+// format=script, скрипт, що відтворює сцену.
+func SceneHandler(loop *painter.Loop) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("format") == "script" {
+			rw.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			_, _ = io.WriteString(rw, FormatScene(loop.Scene()))
+			return
+		}
+		writeJSON(rw, http.StatusOK, loop.Scene())
+	})
+}
+
+// FiltersHandler конструює обробник HTTP запитів для керування фільтрами кадру.
+// GET повертає поточний ланцюжок, POST замінює його фільтрами з тіла запиту (по одному на рядок),
+// DELETE вимикає усі фільтри. Після зміни кадр перемальовується.
+func FiltersHandler(loop *painter.Loop) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		var filters []painter.Filter
+
+		switch r.Method {
+		case http.MethodGet:
+			names := loop.Scene().Filters
+			if names == nil {
+				names = []string{}
+			}
+			writeJSON(rw, http.StatusOK, names)
+			return
+		case http.MethodPost:
+			body, ok := readBody(rw, r)
+			if !ok {
+				return
+			}
+			var err error
+			if filters, err = ParseFilters(bytes.NewReader(body)); err != nil {
+				writeParseError(rw, err)
+				return
+			}
+		case http.MethodDelete:
+		default:
+			rw.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		loop.Post(painter.OperationList{painter.SetFilters{Filters: filters}, painter.UpdateOp})
 		rw.WriteHeader(http.StatusOK)
 	})
 }
+
+// ProcsHandler конструює обробник HTTP запитів для спільних процедур парсера. GET /procs повертає усі процедури,
+// POST /procs додає процедури, визначені у тілі запиту, GET /procs/<назва> повертає процедуру,
+// DELETE /procs/<назва> видаляє її.
+func ProcsHandler(p *Parser) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		name := strings.Trim(strings.TrimPrefix(r.URL.Path, "/procs"), "/")
+
+		switch {
+		case name == "" && r.Method == http.MethodGet:
+			writeJSON(rw, http.StatusOK, p.Procs.List())
+		case name == "" && r.Method == http.MethodPost:
+			body, ok := readBody(rw, r)
+			if !ok {
+				return
+			}
+			procs, err := p.ParseProcs(bytes.NewReader(body))
+			if err != nil {
+				writeParseError(rw, err)
+				return
+			}
+			writeJSON(rw, http.StatusOK, procs)
+		case name != "" && r.Method == http.MethodGet:
+			proc := p.Procs.Get(name)
+			if proc == nil {
+				writeJSON(rw, http.StatusNotFound, errorResponse{Error: "unknown procedure"})
+				return
+			}
+			writeJSON(rw, http.StatusOK, proc)
+		case name != "" && r.Method == http.MethodDelete:
+			if !p.Procs.Delete(name) {
+				writeJSON(rw, http.StatusNotFound, errorResponse{Error: "unknown procedure"})
+				return
+			}
+			rw.WriteHeader(http.StatusOK)
+		default:
+			rw.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+// HitTestHandler конструює обробник HTTP запитів виду /scene/at?x=0.5&y=0.5, який повертає у форматі JSON
+// об'єкти сцени у вказаній точці, починаючи з верхнього.
+func HitTestHandler(loop *painter.Loop) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		params, err := queryParams(r, "x", "y")
+		if err != nil {
+			writeJSON(rw, http.StatusBadRequest, errorResponse{Error: err.Error()})
+			return
+		}
+
+		hits := loop.HitTest(params[0], params[1])
+		if hits == nil {
+			hits = []painter.Hit{}
+		}
+		writeJSON(rw, http.StatusOK, hits)
+	})
+}
+
+// IntersectHandler конструює обробник HTTP запитів виду /scene/intersect?x1=0&y1=0&x2=0.5&y2=0.5, який повертає
+// у форматі JSON фігури, що перетинаються з прямокутником.
+func IntersectHandler(loop *painter.Loop) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		params, err := queryParams(r, "x1", "y1", "x2", "y2")
+		if err != nil {
+			writeJSON(rw, http.StatusBadRequest, errorResponse{Error: err.Error()})
+			return
+		}
+
+		writeJSON(rw, http.StatusOK, loop.FiguresIn(params[0], params[1], params[2], params[3]))
+	})
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// parseErrorResponse описує усі проблеми скрипта разом з місцями, де вони виникли.
+type parseErrorResponse struct {
+	Error       string      `json:"error"`
+	Diagnostics Diagnostics `json:"diagnostics,omitempty"`
+}
+
+// postResponse описує порушення меж та попередження скрипта, операції якого відправлено у цикл.
+type postResponse struct {
+	Error      string                `json:"error,omitempty"`
+	Violations []painter.BoundsError `json:"violations"`
+	Warnings   Diagnostics           `json:"warnings,omitempty"`
+}
+
+// dryRunResponse відповідь на пробний запуск: сцена після операцій та, за запитом, її кадр у PNG,
+// закодований у base64.
+type dryRunResponse struct {
+	postResponse
+	Scene   *painter.Scene `json:"scene,omitempty"`
+	Preview []byte         `json:"preview,omitempty"`
+}
+
+// queryParams зчитує координати з параметрів запиту у вказаному порядку.
+func queryParams(r *http.Request, names ...string) ([]float32, error) {
+	res := make([]float32, len(names))
+	for i, name := range names {
+		v, err := strconv.ParseFloat(r.URL.Query().Get(name), 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid param %s", name)
+		}
+		if v < 0 || v > 1 {
+			return nil, fmt.Errorf("invalid coordinates: %s must be in [0, 1]", name)
+		}
+		res[i] = float32(v)
+	}
+	return res, nil
+}
+
+// writeParseError відповідає клієнту на скрипт з помилками, перелічуючи усі знайдені проблеми.
+func writeParseError(rw http.ResponseWriter, err error) {
+	res := parseErrorResponse{Error: "invalid script"}
+	if !errors.As(err, &res.Diagnostics) {
+		res.Error = err.Error()
+	}
+	writeJSON(rw, http.StatusBadRequest, res)
+}
+
+func writeJSON(rw http.ResponseWriter, status int, v any) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(status)
+	if err := json.NewEncoder(rw).Encode(v); err != nil {
+		log.Printf("Failed to write response: %s", err)
+	}
+}
@@ -0,0 +1,213 @@
+package lang
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/roman-mazur/architecture-lab-3/painter"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/exp/shiny/screen"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type mockReceiver struct{}
+
+func (mockReceiver) Update(_ screen.Texture) {}
+
+type mockScreen struct{}
+
+func (mockScreen) NewBuffer(_ image.Point) (screen.Buffer, error) {
+	return nil, errors.New("nothing")
+}
+func (mockScreen) NewTexture(_ image.Point) (screen.Texture, error) {
+	return nil, errors.New("nothing")
+}
+func (mockScreen) NewWindow(_ *screen.NewWindowOptions) (screen.Window, error) {
+	return nil, errors.New("nothing")
+}
+
+// testResponse поля усіх JSON відповідей обробників.
+type testResponse struct {
+	Error       string                `json:"error"`
+	Violations  []painter.BoundsError `json:"violations"`
+	Warnings    []testDiagnostic      `json:"warnings"`
+	Diagnostics []testDiagnostic      `json:"diagnostics"`
+	Scene       *painter.Scene        `json:"scene"`
+	Preview     []byte                `json:"preview"`
+}
+
+type testDiagnostic struct {
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+}
+
+func startLoop(bounds painter.BoundsPolicy) *painter.Loop {
+	loop := &painter.Loop{Receiver: mockReceiver{}, Bounds: bounds}
+	loop.Start(mockScreen{})
+	return loop
+}
+
+// request виконує запит до обробника.
+func request(h http.Handler, method, target, contentType, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+// serve виконує запит до обробника та розбирає JSON відповідь, якщо вона є.
+func serve(t *testing.T, h http.Handler, method, target, contentType, body string) (int, testResponse) {
+	rec := request(h, method, target, contentType, body)
+
+	var res testResponse
+	if rec.Header().Get("Content-Type") == "application/json" {
+		assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &res), rec.Body.String())
+	}
+	return rec.Code, res
+}
+
+func TestHttpHandler(t *testing.T) {
+	loop := startLoop(painter.BoundsReject)
+	h := HttpHandler(loop, &Parser{})
+
+	code, _ := serve(t, h, http.MethodPost, "/", "text/plain", "figure 0.5 0.5\nupdate")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Len(t, loop.Scene().Figures, 1)
+
+	code, _ = serve(t, h, http.MethodPost, "/", "application/json", `[{"op": "figure", "x": 0.45, "y": 0.4}, {"op": "update"}]`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Len(t, loop.Scene().Figures, 2)
+
+	// JSON тіло без відповідного Content-Type розбирається як скрипт.
+	code, res := serve(t, h, http.MethodPost, "/", "text/plain", `[{"op": "update"}]`)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "invalid script", res.Error)
+
+	code, res = serve(t, h, http.MethodPost, "/", "", "figure 0 0\nupdate")
+	assert.Equal(t, http.StatusUnprocessableEntity, code)
+	assert.Equal(t, "figures out of bounds", res.Error)
+	if assert.Len(t, res.Violations, 1) {
+		assert.Equal(t, "figure", res.Violations[0].Op)
+	}
+	assert.Len(t, loop.Scene().Figures, 2)
+
+	code, res = serve(t, h, http.MethodPost, "/", "", "white\nwhite\nupdate")
+	assert.Equal(t, http.StatusOK, code)
+	if assert.Len(t, res.Warnings, 1) {
+		assert.Equal(t, 1, res.Warnings[0].Line)
+	}
+	assert.Empty(t, res.Violations)
+}
+
+func TestHttpHandler_DryRun(t *testing.T) {
+	loop := startLoop(painter.BoundsReject)
+	h := HttpHandler(loop, &Parser{})
+
+	code, res := serve(t, h, http.MethodPost, "/?dryRun=1&preview=1", "", "bgrect 0 0 0.1 0.1\nfigure 0.5 0.5\nupdate")
+	assert.Equal(t, http.StatusOK, code)
+	if assert.NotNil(t, res.Scene) {
+		assert.Len(t, res.Scene.Figures, 1)
+		assert.NotNil(t, res.Scene.Rect)
+	}
+	img, err := png.Decode(bytes.NewReader(res.Preview))
+	if assert.Nil(t, err) {
+		assert.Equal(t, image.Pt(600, 600), img.Bounds().Size())
+	}
+	assert.Empty(t, loop.Scene().Figures)
+
+	code, res = serve(t, h, http.MethodGet, "/?dryRun=true&cmd=figure+0+0", "", "")
+	assert.Equal(t, http.StatusUnprocessableEntity, code)
+	assert.Len(t, res.Violations, 1)
+	assert.Nil(t, res.Scene)
+
+	code, _ = serve(t, h, http.MethodPost, "/?dryRun=maybe", "", "update")
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, res = serve(t, h, http.MethodPost, "/?dryRun=0", "", "figure 0.5 0.5")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Nil(t, res.Scene)
+	assert.Len(t, loop.Scene().Figures, 1)
+}
+
+func TestHttpHandler_BodyLimit(t *testing.T) {
+	h := HttpHandler(startLoop(painter.BoundsAllow), &Parser{})
+
+	code, _ := serve(t, h, http.MethodPost, "/", "", strings.Repeat("update\n", maxScriptSize/7+1))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, code)
+}
+
+func TestScriptsHandler(t *testing.T) {
+	loop := startLoop(painter.BoundsAllow)
+	parser := &Parser{Scripts: &Library{Dir: t.TempDir()}}
+	h := ScriptsHandler(loop, parser)
+
+	const script = "figure x 0.5\nupdate"
+	code, _ := serve(t, h, http.MethodPut, "/scripts/dot.paint", "", script)
+	assert.Equal(t, http.StatusOK, code)
+
+	rec := request(h, http.MethodGet, "/scripts", "", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `["dot.paint"]`, rec.Body.String())
+
+	rec = request(h, http.MethodGet, "/scripts/dot.paint", "", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, script, rec.Body.String())
+
+	code, _ = serve(t, h, http.MethodPost, "/scripts/dot.paint/run", "application/json", `{"x": 0.4}`)
+	assert.Equal(t, http.StatusOK, code)
+	if figs := loop.Scene().Figures; assert.Len(t, figs, 1) {
+		assert.Equal(t, float32(0.4), figs[0].X)
+	}
+
+	code, res := serve(t, h, http.MethodGet, "/scripts/nope.paint", "", "")
+	assert.Equal(t, http.StatusNotFound, code)
+	assert.Equal(t, "unknown script", res.Error)
+
+	code, _ = serve(t, h, http.MethodPost, "/scripts/nope.paint/run", "", "")
+	assert.Equal(t, http.StatusNotFound, code)
+
+	code, res = serve(t, h, http.MethodPost, "/scripts/dot.paint/run", "application/json", `{"x": "left"}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.True(t, strings.HasPrefix(res.Error, "invalid params"), res.Error)
+
+	// Без параметра x скрипт не розбирається.
+	code, res = serve(t, h, http.MethodPost, "/scripts/dot.paint/run", "", "")
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "invalid script", res.Error)
+
+	large := `{"x": 0.4, "pad": "` + strings.Repeat("a", maxScriptSize) + `"}`
+	code, _ = serve(t, h, http.MethodPost, "/scripts/dot.paint/run", "application/json", large)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, code)
+	assert.Len(t, loop.Scene().Figures, 1)
+}
+
+func TestTemplateHandler(t *testing.T) {
+	loop := startLoop(painter.BoundsAllow)
+	h := TemplateHandler(loop, &Parser{})
+
+	code, _ := serve(t, h, http.MethodPost, "/template", "application/json",
+		`{"template": "{{range .xs}}figure {{.}} 0.5\n{{end}}update", "params": {"xs": [0.25, 0.75]}}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Len(t, loop.Scene().Figures, 2)
+
+	for _, body := range []string{
+		`{"template": "{{range 2000000000}}{{range 2000000000}}update\n{{end}}{{end}}"}`,
+		`{"template": "figure {{.x}} 0.5", "params": {}}`,
+		`{"template": "figure {{.c}} 0.5 bogus", "params": {"c": "#zz"}}`,
+		`{"template": "update", "script": "a.paint"}`,
+		`{"template": `,
+	} {
+		code, res := serve(t, h, http.MethodPost, "/template", "application/json", body)
+		assert.Equal(t, http.StatusBadRequest, code, body)
+		assert.NotEmpty(t, res.Error, body)
+	}
+	assert.Len(t, loop.Scene().Figures, 2)
+}
@@ -0,0 +1,145 @@
+package lang
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxIncludeDepth обмежує вкладеність команд include.
+const maxIncludeDepth = 8
+
+// ScriptExt розширення файлів скриптів у бібліотеці.
+const ScriptExt = ".paint"
+
+var (
+	// ErrNoLibrary повертається, якщо парсер не має бібліотеки скриптів.
+	ErrNoLibrary = errors.New("script library is not configured")
+	// ErrInvalidName повертається для некоректних назв скриптів та параметрів.
+	ErrInvalidName = errors.New("invalid name")
+)
+
+// Library бібліотека скриптів, що зберігаються як файли з розширенням ScriptExt у каталозі Dir.
+type Library struct {
+	Dir string
+}
+
+// ValidScriptName перевіряє, чи назва може бути назвою скрипта бібліотеки: назва файлу з розширенням ScriptExt
+// без шляху, що складається з літер, цифр, '_', '-' та '.'.
+func ValidScriptName(name string) bool {
+	if !strings.HasSuffix(name, ScriptExt) || len(name) == len(ScriptExt) || name[0] == '.' {
+		return false
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-' || r == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+func (l *Library) path(name string) (string, error) {
+	if l == nil {
+		return "", ErrNoLibrary
+	}
+	if !ValidScriptName(name) {
+		return "", fmt.Errorf("%w: script %q", ErrInvalidName, name)
+	}
+	return filepath.Join(l.Dir, name), nil
+}
+
+// List повертає назви усіх скриптів бібліотеки, впорядковані за абеткою.
+func (l *Library) List() ([]string, error) {
+	if l == nil {
+		return nil, ErrNoLibrary
+	}
+	entries, err := os.ReadDir(l.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for _, e := range entries {
+		if e.Type().IsRegular() && ValidScriptName(e.Name()) {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Read повертає текст скрипта. Якщо скрипта немає, помилка відповідає os.ErrNotExist.
+func (l *Library) Read(name string) ([]byte, error) {
+	path, err := l.path(name)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(path)
+}
+
+// Write зберігає скрипт, замінюючи скрипт з такою ж назвою.
+func (l *Library) Write(name string, data []byte) error {
+	path, err := l.path(name)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// include виконує команду `include "<назва>"`, розгортаючи скрипт бібліотеки так, ніби його текст записано
+// у місці включення. Змінні та процедури включеного скрипта доступні після include.
+func (x *expander) include(c *command, st statement) error {
+	text, err := c.next("script name")
+	if err != nil {
+		return err
+	}
+	name := text
+	if strings.HasPrefix(text, `"`) {
+		if name, err = strconv.Unquote(text); err != nil {
+			return c.errorAt(c.pos-1, "invalid params", `quoted script name`)
+		}
+	}
+	if err := c.end(); err != nil {
+		return err
+	}
+
+	for _, file := range x.files {
+		if file == name {
+			chain := strings.Join(append(x.files, name), " -> ")
+			return c.errorAt(0, "include cycle: "+chain, "")
+		}
+	}
+	if len(x.files) >= maxIncludeDepth {
+		return c.errorAt(0, "includes nested too deeply", "")
+	}
+
+	data, err := x.scripts.Read(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return c.errorAt(0, "unknown script", "script from the library")
+	}
+	if err != nil {
+		return c.errorAt(0, err.Error(), "")
+	}
+
+	lines, stmts, diags := readScript(strings.NewReader(string(data)))
+
+	savedText, savedSite := x.text, x.site
+	if x.site == nil {
+		x.site = &st
+	}
+	x.text = lines
+	x.files = append(x.files, name)
+
+	for _, d := range diags {
+		x.report(d)
+	}
+	x.run(stmts)
+
+	x.files = x.files[:len(x.files)-1]
+	x.text, x.site = savedText, savedSite
+	return nil
+}
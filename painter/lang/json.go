@@ -0,0 +1,559 @@
+package lang
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/roman-mazur/architecture-lab-3/painter"
+)
+
+// fieldKind описує, як поле JSON команди записується у словах команди скрипта.
+type fieldKind int
+
+const (
+	fieldNumber  fieldKind = iota // Число
+	fieldInteger                  // Ціле число
+	fieldString                   // Одне слово: назва, колір чи значення з переліку
+	fieldValue                    // Число або слово
+	fieldFlag                     // true додає слово з назвою поля
+	fieldKeyword                  // Значення записується після слова з назвою поля, наприклад "group <назва>"
+	fieldOption                   // Значення записується як "<назва поля>=<значення>"
+	fieldDash                     // Список цілих чисел, записується як "dash=<a>,<b>,..."
+	fieldArea                     // Чотири координати області, записуються після слова "in"
+	fieldMembers                  // Список ідентифікаторів фігур та назв груп
+	fieldPattern                  // Візерунок
+	fieldStops                    // Опорні точки градієнта
+)
+
+// jsonField поле JSON команди. Поля записуються у слова команди скрипта у порядку їх оголошення.
+type jsonField struct {
+	name     string
+	kind     fieldKind
+	value    fieldKind // Тип значення для fieldKeyword та fieldOption
+	required bool
+	enum     []string
+	maximum  int // Найбільше значення цілого поля, якщо не 0
+}
+
+func numberField(name string) jsonField {
+	return jsonField{name: name, kind: fieldNumber, required: true}
+}
+
+func integerField(name string) jsonField {
+	return jsonField{name: name, kind: fieldInteger, required: true}
+}
+
+func flagField(name string) jsonField {
+	return jsonField{name: name, kind: fieldFlag}
+}
+
+func stringField(name string, enum ...string) jsonField {
+	return jsonField{name: name, kind: fieldString, required: true, enum: enum}
+}
+
+func keywordField(name string, value fieldKind) jsonField {
+	return jsonField{name: name, kind: fieldKeyword, value: value}
+}
+
+func optionField(name string, value fieldKind) jsonField {
+	return jsonField{name: name, kind: fieldOption, value: value}
+}
+
+func optional(f jsonField) jsonField {
+	f.required = false
+	return f
+}
+
+func coordFields(names ...string) []jsonField {
+	res := make([]jsonField, len(names))
+	for i, name := range names {
+		res[i] = numberField(name)
+	}
+	return res
+}
+
+// fields з'єднує поля та списки полів в один список.
+func fields(items ...any) []jsonField {
+	var res []jsonField
+	for _, item := range items {
+		switch item := item.(type) {
+		case jsonField:
+			res = append(res, item)
+		case []jsonField:
+			res = append(res, item...)
+		}
+	}
+	return res
+}
+
+var (
+	targetFields = []jsonField{flagField("rect"), keywordField("figure", fieldInteger), keywordField("group", fieldString)}
+	groupField   = keywordField("group", fieldString)
+	seedField    = optionField("seed", fieldInteger)
+	areaField    = jsonField{name: "in", kind: fieldArea}
+)
+
+// jsonCommand JSON команда з полем "op", що відповідає команді скрипта, яка починається словами words.
+type jsonCommand struct {
+	op     string
+	words  []string
+	fields []jsonField
+}
+
+var jsonCommands = []jsonCommand{
+	{"white", []string{"white"}, nil},
+	{"green", []string{"green"}, nil},
+	{"update", []string{"update"}, nil},
+	{"reset", []string{"reset"}, nil},
+	{"bgrect", []string{"bgrect"}, coordFields("x1", "y1", "x2", "y2")},
+	{"figure", []string{"figure"}, fields(coordFields("x", "y"), optionField("scale", fieldNumber), optionField("color", fieldString))},
+	{"move", []string{"move"}, fields(groupField, coordFields("x", "y"))},
+	{"group", []string{"group"}, fields(stringField("name"), jsonField{name: "members", kind: fieldMembers, required: true})},
+	{"scale", []string{"scale"}, fields(jsonField{name: "group", kind: fieldKeyword, value: fieldString, required: true}, numberField("factor"))},
+	{"recolor", []string{"recolor"}, fields(jsonField{name: "group", kind: fieldKeyword, value: fieldString, required: true}, stringField("color"))},
+	{"deleteFigure", []string{"delete", "figure"}, fields(integerField("id"))},
+	{"deleteIndex", []string{"delete", "index"}, fields(integerField("index"))},
+	{"deleteAt", []string{"delete", "at"}, coordFields("x", "y")},
+	{"deleteRect", []string{"delete", "rect"}, nil},
+	{"deleteGroup", []string{"delete", "group"}, fields(stringField("name"))},
+	{"replaceFigure", []string{"replace", "figure"}, fields(integerField("id"), coordFields("x", "y"), optionField("scale", fieldNumber), optionField("color", fieldString))},
+	{"replaceRect", []string{"replace", "rect"}, coordFields("x1", "y1", "x2", "y2")},
+	{"layoutGrid", []string{"layout", "grid"}, fields(integerField("rows"), integerField("cols"), optional(numberField("margin")), groupField)},
+	{"layoutLine", []string{"layout", "line"}, fields(coordFields("x1", "y1", "x2", "y2"), groupField)},
+	{"layoutCircle", []string{"layout", "circle"}, fields(coordFields("x", "y", "r"), groupField)},
+	{"velocity", []string{"velocity"}, fields(targetFields[1:], coordFields("vx", "vy"))},
+	{"velocityRandom", []string{"velocity", "random"}, fields(numberField("max"), seedField)},
+	{"accel", []string{"accel"}, fields(targetFields[1:], coordFields("ax", "ay"))},
+	{"simulate", []string{"simulate"}, fields(stringField("mode", "on", "off"), flagField("collide"))},
+	{"fill", []string{"fill"}, fields(targetFields, optional(stringField("color")), jsonField{name: "pattern", kind: fieldPattern})},
+	{"filter", []string{"filter"}, fields(
+		stringField("filter", "clear", "grayscale", "invert", "blur", "pixelate", "brightness", "contrast", "colorblind"),
+		jsonField{name: "value", kind: fieldValue},
+	)},
+	{"clipRect", []string{"clip", "rect"}, coordFields("x1", "y1", "x2", "y2")},
+	{"clipCircle", []string{"clip", "circle"}, coordFields("x", "y", "r")},
+	{"clipBgrect", []string{"clip", "bgrect"}, nil},
+	{"unclip", []string{"unclip"}, fields(flagField("all"))},
+	{"stroke", []string{"stroke"}, fields(targetFields, flagField("none"), optional(stringField("color")), optional(integerField("width")), jsonField{name: "dash", kind: fieldDash})},
+	{"hollow", []string{"hollow"}, targetFields},
+	{"solid", []string{"solid"}, targetFields},
+	{"shadow", []string{"shadow"}, fields(targetFields, flagField("none"), optional(integerField("dx")), optional(integerField("dy")), optional(jsonField{name: "blur", kind: fieldInteger, maximum: painter.MaxEffectRadius}), optional(stringField("color")))},
+	{"glow", []string{"glow"}, fields(targetFields, flagField("none"), optional(jsonField{name: "radius", kind: fieldInteger, maximum: painter.MaxEffectRadius}), optional(stringField("color")))},
+	{"randomFigures", []string{"random", "figures"}, fields(integerField("count"), areaField, flagField("colors"), seedField)},
+	{"randomRect", []string{"random", "rect"}, fields(areaField, seedField)},
+	{"randomColors", []string{"random", "colors"}, fields(groupField, seedField)},
+	{"scatter", []string{"scatter"}, fields(groupField, areaField, seedField)},
+	{"jitter", []string{"jitter"}, fields(numberField("amount"), groupField, seedField)},
+}
+
+// jsonPatterns поля візерунків за їх типом.
+var jsonPatterns = []struct {
+	kind   string
+	fields []jsonField
+}{
+	{"linear", fields(numberField("angle"), jsonField{name: "stops", kind: fieldStops, required: true})},
+	{"radial", fields(coordFields("cx", "cy"), jsonField{name: "stops", kind: fieldStops, required: true})},
+	{"checker", fields(numberField("size"), stringField("a"), stringField("b"))},
+	{"stripes", fields(numberField("width"), numberField("angle"), stringField("a"), stringField("b"))},
+}
+
+// ParseJSON розбирає команди у форматі JSON: масив об'єктів, поле "op" яких задає команду, а решта полів — її
+// аргументи, наприклад {"op": "figure", "x": 0.5, "y": 0.5}. Команди відповідають командам скрипта та дають
+// ті самі операції. Схема команд повертається CommandSchema. Помилки містять JSON шлях до некоректного значення.
+func (p *Parser) ParseJSON(in io.Reader) ([]painter.Operation, error) {
+	res, diags := p.ParseJSONDiagnostics(in)
+	return res, diags.Err()
+}
+
+// ParseJSONDiagnostics розбирає усі JSON команди, не зупиняючись на командах з помилками, так само як ParseDiagnostics.
+func (p *Parser) ParseJSONDiagnostics(in io.Reader) ([]painter.Operation, Diagnostics) {
+	var items []json.RawMessage
+	if err := json.NewDecoder(in).Decode(&items); err != nil {
+		return nil, Diagnostics{{Path: "$", Arg: -1, Message: "invalid JSON: " + err.Error(), Expected: "array of commands"}}
+	}
+
+	var (
+		ops   []painter.Operation
+		sites []opSite
+		diags Diagnostics
+	)
+	for i, item := range items {
+		path := fmt.Sprintf("$[%d]", i)
+		args := &jsonArgs{line: i + 1}
+		if err := args.command(item, path); err != nil {
+			if !diags.add(err) {
+				break
+			}
+			continue
+		}
+
+		c := newCommand(args.tokens, args.source, map[string]float64{})
+		op, err := parseCommand(c)
+		if err != nil {
+			e := asParseError(err, c.name, args.source)
+			e.Path = path
+			if e.Arg >= 0 && e.Arg+1 < len(args.paths) {
+				e.Path = args.paths[e.Arg+1]
+			}
+			if !diags.add(e) {
+				break
+			}
+			continue
+		}
+		if op != nil {
+			ops = append(ops, op)
+			sites = append(sites, opSite{name: args.tokens[0], source: args.source, path: path})
+		}
+	}
+
+	if diags.Err() == nil {
+		for _, w := range lint(ops, sites) {
+			diags.add(w)
+		}
+	}
+	diags.sort()
+	return ops, diags
+}
+
+// jsonArgs слова команди скрипта, отримані з JSON команди, разом з JSON шляхами до їх значень.
+type jsonArgs struct {
+	line   int
+	tokens []token
+	paths  []string
+	source string
+}
+
+func (a *jsonArgs) add(text, path string) {
+	if len(a.tokens) > 0 {
+		a.source += " "
+	}
+	a.tokens = append(a.tokens, token{text: text, line: a.line, col: utf8.RuneCountInString(a.source) + 1})
+	a.paths = append(a.paths, path)
+	a.source += text
+}
+
+func (a *jsonArgs) errorAt(path, message, expected string, got json.RawMessage) *ParseError {
+	e := &ParseError{Line: a.line, Column: 1, Arg: -1, Path: path, Message: message, Expected: expected, Source: a.source}
+	if len(got) > 0 {
+		e.Got = string(got)
+	}
+	if len(a.tokens) > 0 {
+		e.Command = a.tokens[0].text
+	}
+	return e
+}
+
+// command перетворює JSON команду item на слова команди скрипта.
+func (a *jsonArgs) command(item json.RawMessage, path string) *ParseError {
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(item, &obj); err != nil || obj == nil {
+		return a.errorAt(path, "invalid command", "object", item)
+	}
+
+	var op string
+	if err := json.Unmarshal(obj["op"], &op); err != nil {
+		return a.errorAt(path+".op", "missing command", "string", obj["op"])
+	}
+	for _, cmd := range jsonCommands {
+		if cmd.op == op {
+			for _, word := range cmd.words {
+				a.add(word, path+".op")
+			}
+			return a.fields(obj, "op", cmd.fields, path)
+		}
+	}
+	return a.errorAt(path+".op", "unknown command", "", obj["op"])
+}
+
+// fields перетворює поля об'єкта obj на слова команди. Поле discriminator задає тип об'єкта.
+func (a *jsonArgs) fields(obj map[string]json.RawMessage, discriminator string, fields []jsonField, path string) *ParseError {
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+outer:
+	for _, k := range keys {
+		if k == discriminator {
+			continue
+		}
+		for _, f := range fields {
+			if f.name == k {
+				continue outer
+			}
+		}
+		return a.errorAt(path+"."+k, "unknown field", "", nil)
+	}
+
+	for _, f := range fields {
+		raw, ok := obj[f.name]
+		fieldPath := path + "." + f.name
+		if !ok {
+			if f.required {
+				return a.errorAt(fieldPath, "missing field", fieldTypeName(f.kind), nil)
+			}
+			continue
+		}
+		if err := a.field(f, raw, fieldPath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *jsonArgs) field(f jsonField, raw json.RawMessage, path string) *ParseError {
+	switch f.kind {
+	case fieldNumber, fieldInteger, fieldString, fieldValue:
+		text, err := a.scalar(f.kind, raw, path)
+		if err != nil {
+			return err
+		}
+		a.add(text, path)
+	case fieldFlag:
+		var set bool
+		if err := json.Unmarshal(raw, &set); err != nil || isNull(raw) {
+			return a.errorAt(path, "invalid field type", "boolean", raw)
+		}
+		if set {
+			a.add(f.name, path)
+		}
+	case fieldKeyword:
+		text, err := a.scalar(f.value, raw, path)
+		if err != nil {
+			return err
+		}
+		a.add(f.name, path)
+		a.add(text, path)
+	case fieldOption:
+		text, err := a.scalar(f.value, raw, path)
+		if err != nil {
+			return err
+		}
+		a.add(f.name+"="+text, path)
+	case fieldDash:
+		items, err := a.array(raw, path)
+		if err != nil {
+			return err
+		}
+		parts := make([]string, len(items))
+		for i, item := range items {
+			if parts[i], err = a.scalar(fieldInteger, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+		a.add("dash="+strings.Join(parts, ","), path)
+	case fieldArea:
+		items, err := a.array(raw, path)
+		if err != nil {
+			return err
+		}
+		if len(items) != 4 {
+			return a.errorAt(path, "invalid params count", "4 coordinates", raw)
+		}
+		a.add("in", path)
+		for i, item := range items {
+			itemPath := fmt.Sprintf("%s[%d]", path, i)
+			text, err := a.scalar(fieldNumber, item, itemPath)
+			if err != nil {
+				return err
+			}
+			a.add(text, itemPath)
+		}
+	case fieldMembers:
+		items, err := a.array(raw, path)
+		if err != nil {
+			return err
+		}
+		for i, item := range items {
+			itemPath := fmt.Sprintf("%s[%d]", path, i)
+			text, err := a.scalar(fieldValue, item, itemPath)
+			if err != nil {
+				return err
+			}
+			a.add(text, itemPath)
+		}
+	case fieldPattern:
+		var obj map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &obj); err != nil || obj == nil {
+			return a.errorAt(path, "invalid field type", "object", raw)
+		}
+		var kind string
+		if err := json.Unmarshal(obj["type"], &kind); err != nil {
+			return a.errorAt(path+".type", "missing field", "string", obj["type"])
+		}
+		for _, p := range jsonPatterns {
+			if p.kind == kind {
+				a.add(kind, path+".type")
+				return a.fields(obj, "type", p.fields, path)
+			}
+		}
+		return a.errorAt(path+".type", "unknown pattern", "one of linear, radial, checker, stripes", obj["type"])
+	case fieldStops:
+		items, err := a.array(raw, path)
+		if err != nil {
+			return err
+		}
+		for i, item := range items {
+			itemPath := fmt.Sprintf("%s[%d]", path, i)
+			var stop struct {
+				Color  *string          `json:"color"`
+				Offset *json.RawMessage `json:"offset"`
+			}
+			if err := json.Unmarshal(item, &stop); err != nil || stop.Color == nil {
+				return a.errorAt(itemPath, "invalid field type", `object {"color": <color>, "offset": <number>}`, item)
+			}
+			text := *stop.Color
+			if stop.Offset != nil {
+				offset, err := a.scalar(fieldNumber, *stop.Offset, itemPath+".offset")
+				if err != nil {
+					return err
+				}
+				text += "@" + offset
+			}
+			a.add(text, itemPath)
+		}
+	}
+	return nil
+}
+
+// scalar повертає слово команди для значення raw типу kind. Числа записуються так, як вони записані у JSON.
+func (a *jsonArgs) scalar(kind fieldKind, raw json.RawMessage, path string) (string, *ParseError) {
+	raw = bytes.TrimSpace(raw)
+	var s string
+	if kind != fieldNumber && kind != fieldInteger && json.Unmarshal(raw, &s) == nil {
+		return s, nil
+	}
+	var v float64
+	if kind != fieldString && !isNull(raw) && json.Unmarshal(raw, &v) == nil {
+		return string(raw), nil
+	}
+	return "", a.errorAt(path, "invalid field type", fieldTypeName(kind), raw)
+}
+
+func (a *jsonArgs) array(raw json.RawMessage, path string) ([]json.RawMessage, *ParseError) {
+	var items []json.RawMessage
+	if err := json.Unmarshal(raw, &items); err != nil || items == nil {
+		return nil, a.errorAt(path, "invalid field type", "array", raw)
+	}
+	return items, nil
+}
+
+func isNull(raw json.RawMessage) bool {
+	return string(bytes.TrimSpace(raw)) == "null"
+}
+
+func fieldTypeName(kind fieldKind) string {
+	switch kind {
+	case fieldNumber:
+		return "number"
+	case fieldInteger:
+		return "integer"
+	case fieldString:
+		return "string"
+	case fieldValue:
+		return "number or string"
+	case fieldFlag:
+		return "boolean"
+	case fieldPattern:
+		return "object"
+	}
+	return "array"
+}
+
+// CommandSchema повертає JSON Schema формату команд, який розбирає ParseJSON.
+func CommandSchema() map[string]any {
+	commands := make([]any, len(jsonCommands))
+	for i, cmd := range jsonCommands {
+		commands[i] = objectSchema("op", cmd.op, cmd.fields)
+	}
+	patterns := make([]any, len(jsonPatterns))
+	for i, p := range jsonPatterns {
+		patterns[i] = objectSchema("type", p.kind, p.fields)
+	}
+
+	return map[string]any{
+		"$schema":     "https://json-schema.org/draft/2020-12/schema",
+		"title":       "painter commands",
+		"description": `Commands for the painter HTTP handler with Content-Type: application/json. Each command maps onto a script command, e.g. {"op": "figure", "x": 0.5, "y": 0.5} is "figure 0.5 0.5".`,
+		"type":        "array",
+		"items":       map[string]any{"oneOf": commands},
+		"$defs": map[string]any{
+			"pattern": map[string]any{"oneOf": patterns},
+			"stop": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"color":  map[string]any{"type": "string"},
+					"offset": map[string]any{"type": "number", "minimum": 0, "maximum": 1},
+				},
+				"required":             []string{"color"},
+				"additionalProperties": false,
+			},
+			"area": map[string]any{
+				"type":     "array",
+				"items":    map[string]any{"type": "number", "minimum": 0, "maximum": 1},
+				"minItems": 4,
+				"maxItems": 4,
+			},
+		},
+	}
+}
+
+// objectSchema повертає схему об'єкта з полем discriminator, рівним value, та полями fields.
+func objectSchema(discriminator, value string, fields []jsonField) map[string]any {
+	props := map[string]any{discriminator: map[string]any{"const": value}}
+	required := []string{discriminator}
+	for _, f := range fields {
+		props[f.name] = fieldSchema(f)
+		if f.required {
+			required = append(required, f.name)
+		}
+	}
+	return map[string]any{
+		"type":                 "object",
+		"properties":           props,
+		"required":             required,
+		"additionalProperties": false,
+	}
+}
+
+func fieldSchema(f jsonField) map[string]any {
+	kind := f.kind
+	if kind == fieldKeyword || kind == fieldOption {
+		kind = f.value
+	}
+
+	switch kind {
+	case fieldNumber:
+		return map[string]any{"type": "number"}
+	case fieldInteger:
+		if f.maximum != 0 {
+			return map[string]any{"type": "integer", "maximum": f.maximum}
+		}
+		return map[string]any{"type": "integer"}
+	case fieldString:
+		if len(f.enum) > 0 {
+			return map[string]any{"type": "string", "enum": f.enum}
+		}
+		return map[string]any{"type": "string"}
+	case fieldValue:
+		return map[string]any{"type": []string{"number", "string"}}
+	case fieldFlag:
+		return map[string]any{"type": "boolean"}
+	case fieldDash:
+		return map[string]any{"type": "array", "items": map[string]any{"type": "integer", "minimum": 1}, "minItems": 1}
+	case fieldArea:
+		return map[string]any{"$ref": "#/$defs/area"}
+	case fieldMembers:
+		return map[string]any{"type": "array", "items": map[string]any{"type": []string{"string", "integer"}}, "minItems": 1}
+	case fieldPattern:
+		return map[string]any{"$ref": "#/$defs/pattern"}
+	default:
+		return map[string]any{"type": "array", "items": map[string]any{"$ref": "#/$defs/stop"}, "minItems": 2}
+	}
+}
@@ -0,0 +1,107 @@
+package lang
+
+import (
+	"strings"
+
+	"github.com/roman-mazur/architecture-lab-3/painter"
+)
+
+// opSite місце у скрипті, з якого отримано операцію. prefix описує місце команди у спільній процедурі чи
+// включеному скрипті, якщо операцію віднесено до місця виклику. path задає JSON шлях команди у форматі JSON.
+type opSite struct {
+	name   token
+	source string
+	prefix string
+	path   string
+}
+
+// lint шукає операції, що не мають видимого результату: зміни без подальшого update, переміщення до появи
+// фігур, прямокутники з переставленими кутами та заливки, перекриті до оновлення кадру.
+// sites містить місця у скрипті для кожної операції ops.
+func lint(ops []painter.Operation, sites []opSite) Diagnostics {
+	var (
+		res  Diagnostics
+		seen = map[reportKey]bool{}
+	)
+	warn := func(i int, message string) {
+		site := sites[i]
+		e := tokenError(site.name, site.source, site.prefix+message)
+		e.Severity = SeverityWarning
+		e.Path = site.path
+
+		// Попередження з тіла циклу повідомляється один раз.
+		key := reportKey{e.Line, e.Column, e.Message}
+		if !seen[key] {
+			seen[key] = true
+			res.add(e)
+		}
+	}
+	checkRect := func(i int, x1, y1, x2, y2 float32) {
+		var reversed []string
+		if x1 > x2 {
+			reversed = append(reversed, "x1 > x2")
+		}
+		if y1 > y2 {
+			reversed = append(reversed, "y1 > y2")
+		}
+		switch {
+		case len(reversed) > 0:
+			warn(i, "rectangle corners are reversed: "+strings.Join(reversed, ", "))
+		case x1 == x2 || y1 == y2:
+			warn(i, "rectangle is empty")
+		}
+	}
+
+	var (
+		figures    bool // Скрипт уже додав фігури
+		simulating bool // Симуляція сама формує кадри
+		lastUpdate = -1
+		bgFill     = -1 // Заливка тла після останнього update
+		rectFill   = -1 // Заливка прямокутника після останнього update
+	)
+	for i, op := range ops {
+		switch op := op.(type) {
+		case painter.Update:
+			lastUpdate, bgFill, rectFill = i, -1, -1
+		case painter.Reset:
+			if bgFill >= 0 {
+				warn(bgFill, "fill is overwritten by reset before update")
+			}
+			if rectFill >= 0 {
+				warn(rectFill, "fill is overwritten by reset before update")
+			}
+			bgFill, rectFill, figures, simulating = -1, -1, false, false
+		case painter.Fill, painter.PatternFill:
+			if bgFill >= 0 {
+				warn(bgFill, "fill is overwritten before update")
+			}
+			bgFill = i
+		case painter.RectFill:
+			if rectFill >= 0 {
+				warn(rectFill, "fill is overwritten before update")
+			}
+			rectFill = i
+		case painter.BgRect:
+			checkRect(i, op.X1, op.Y1, op.X2, op.Y2)
+		case painter.ReplaceRect:
+			checkRect(i, op.Rect.X1, op.Rect.Y1, op.Rect.X2, op.Rect.Y2)
+		case painter.PushClip:
+			if r, ok := op.Clip.(painter.ClipRect); ok {
+				checkRect(i, r.X1, r.Y1, r.X2, r.Y2)
+			}
+		case painter.Figure, painter.RandomFigures:
+			figures = true
+		case painter.Move, painter.LayoutGrid, painter.LayoutLine, painter.LayoutCircle, painter.Scatter, painter.Jitter:
+			if !figures {
+				warn(i, "moves figures before the script adds any figure")
+			}
+		case painter.Simulate:
+			simulating = op.Enabled
+		}
+	}
+
+	if len(ops) > 0 && !simulating && lastUpdate < len(ops)-1 {
+		warn(lastUpdate+1, "no update after this command, changes are not shown")
+	}
+	return res
+}
@@ -2,7 +2,9 @@ package lang
 
 import (
 	"bufio"
+	"bytes"
 	"errors"
+	"fmt"
 	"image/color"
 	"io"
 	"strconv"
@@ -12,89 +14,735 @@ import (
 )
 
 // Parser уміє прочитати дані з вхідного io.Reader та повернути список операцій представлені вхідним скриптом.
+// Команди записуються по одній на рядок або розділяються ';'. Порожні рядки та коментарі, що починаються з '#' або "//"
+// на початку слова, пропускаються; слово-колір на кшталт "#f00" коментар не починає. Числові аргументи можуть бути
+// виразами зі змінними, заданими командою let; вирази обчислюються під час розбору. Блоки "repeat <n> { ... }" та
+// "for <змінна> in <від>..<до> [step <крок>] { ... }" повторюють свої команди, розгортаючись у звичайні операції.
+// Процедури, визначені через "proc", викликаються як "<назва>(<аргумент>, ...)". Команда `include "<назва>"`
+// вставляє скрипт з бібліотеки Scripts.
 type Parser struct {
+	Procs   Procs    // Процедури, доступні усім скриптам
+	Scripts *Library // Бібліотека скриптів; nil вимикає include
 }
 
 func (p *Parser) Parse(in io.Reader) ([]painter.Operation, error) {
-	var res []painter.Operation
-	scanner := bufio.NewScanner(in)
-	scanner.Split(bufio.ScanLines)
+	res, diags := p.ParseDiagnostics(in)
+	return res, diags.Err()
+}
 
-	for scanner.Scan() {
-		commandLine := scanner.Text()
-		op, err := parseCommand(commandLine)
+// ParseDiagnostics розбирає увесь скрипт, не зупиняючись на командах з помилками, та повертає операції коректних
+// команд разом з повідомленнями про усі знайдені проблеми. Цикли та виклики процедур розгортаються у послідовність
+// операцій. Якщо помилок немає, повідомлення містять попередження про команди без видимого результату.
+func (p *Parser) ParseDiagnostics(in io.Reader) ([]painter.Operation, Diagnostics) {
+	return p.expand(in, "", nil)
+}
+
+// ParseScript розбирає скрипт name з бібліотеки Scripts. Значення params задаються як змінні перед першою командою.
+func (p *Parser) ParseScript(name string, params map[string]float64) ([]painter.Operation, error) {
+	res, diags, err := p.scriptDiagnostics(name, params)
+	if err != nil {
+		return nil, err
+	}
+	return res, diags.Err()
+}
+
+// scriptDiagnostics розбирає скрипт бібліотеки як ParseScript, повертаючи усі повідомлення про нього.
+// Помилка означає, що скрипт не вдалося прочитати.
+func (p *Parser) scriptDiagnostics(name string, params map[string]float64) ([]painter.Operation, Diagnostics, error) {
+	data, err := p.Scripts.Read(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	for param := range params {
+		if !isVariable(param) {
+			return nil, nil, fmt.Errorf("%w: parameter %q", ErrInvalidName, param)
+		}
+	}
+
+	res, diags := p.expand(bytes.NewReader(data), name, params)
+	return res, diags, nil
+}
+
+// expand розбирає скрипт file та розгортає його у список операцій, починаючи зі змінних vars.
+func (p *Parser) expand(in io.Reader, file string, vars map[string]float64) ([]painter.Operation, Diagnostics) {
+	text, stmts, diags := readScript(in)
 
+	x := newExpander(text, p)
+	for k, v := range vars {
+		x.vars[k] = v
+	}
+	if file != "" {
+		x.files = []string{file}
+	}
+	x.diags = diags
+	x.run(stmts)
+	if x.diags.Err() == nil {
+		for _, w := range lint(x.ops, x.sites) {
+			x.diags.add(w)
+		}
+	}
+	x.diags.sort()
+	return x.ops, x.diags
+}
+
+// ParseProcs розбирає скрипт, що складається лише з визначень процедур, та додає їх до спільних процедур.
+// Якщо у скрипті є помилки, жодна процедура не додається.
+func (p *Parser) ParseProcs(in io.Reader) ([]*Proc, error) {
+	text, stmts, diags := readScript(in)
+
+	var procs []*Proc
+	for _, st := range stmts {
+		c := newCommand(st.tokens, st.source, nil)
+		if !st.block || c.name.text != "proc" {
+			diags.add(c.errorAt(-1, "only procedure definitions are allowed", "proc"))
+			continue
+		}
+		proc, err := parseProc(c, st.body, text, st.end)
 		if err != nil {
-			return res, err
+			diags.add(asParseError(err, c.name, st.source))
+			continue
 		}
+		procs = append(procs, proc)
+	}
 
-		res = append(res, op)
+	if err := diags.Err(); err != nil {
+		diags.sort()
+		return nil, diags
+	}
+	for _, proc := range procs {
+		p.Procs.Define(proc)
 	}
+	return procs, nil
+}
 
-	return res, nil
+// readScript читає рядки скрипта та будує з його команд дерево блоків.
+func readScript(in io.Reader) ([]string, []statement, Diagnostics) {
+	var (
+		text  []string
+		lines []sourceCommand
+		diags Diagnostics
+	)
+	scanner := bufio.NewScanner(in)
+	scanner.Split(bufio.ScanLines)
+
+	lineNum := 1
+	for ; scanner.Scan(); lineNum++ {
+		commandLine := scanner.Text()
+		text = append(text, commandLine)
+		for _, tokens := range splitCommands(commandLine, lineNum) {
+			lines = append(lines, sourceCommand{tokens: tokens, source: commandLine})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		diags.add(&ParseError{Line: lineNum, Column: 1, Arg: -1, Message: err.Error()})
+	}
+
+	pos := 0
+	stmts, _ := buildStatements(lines, &pos, false, &diags)
+	return text, stmts, diags
+}
+
+// asParseError приводить помилку розбору команди, що починається з name, до *ParseError.
+func asParseError(err error, name token, source string) *ParseError {
+	var parseErr *ParseError
+	if errors.As(err, &parseErr) {
+		return parseErr
+	}
+	return &ParseError{Line: name.line, Column: name.col, Command: name.text, Arg: -1, Message: err.Error(), Source: source}
 }
 
-func parseCommand(commandLine string) (painter.Operation, error) {
-	parsedCommand := strings.Fields(commandLine)
-	commandName := parsedCommand[0]
-	commandParams := parsedCommand[1:]
+func parseCommand(c *command) (painter.Operation, error) {
+	var (
+		op  painter.Operation
+		err error
+	)
 
-	switch commandName {
+	switch c.name.text {
+	case "let":
+		err = parseLet(c)
 	case "white":
-		return painter.Fill{Color: color.White}, nil
+		op = painter.Fill{Color: color.White}
 	case "green":
-		return painter.Fill{Color: color.RGBA{G: 0xff, A: 0xff}}, nil
+		op = painter.Fill{Color: color.RGBA{G: 0xff, A: 0xff}}
 	case "update":
-		return painter.UpdateOp, nil
+		op = painter.UpdateOp
+	case "reset":
+		op = painter.ResetOp
 	case "bgrect":
-		params, err := parseParams(commandParams, 4)
-		if err != nil {
+		var coords []float32
+		if coords, err = c.coords(4); err == nil {
+			op = painter.BgRect{X1: coords[0], Y1: coords[1], X2: coords[2], Y2: coords[3]}
+		}
+	case "figure":
+		op, err = parseFigure(c)
+	case "move":
+		op, err = parseMove(c)
+	case "group":
+		op, err = parseGroup(c)
+	case "scale":
+		op, err = parseScale(c)
+	case "recolor":
+		op, err = parseRecolor(c)
+	case "delete":
+		op, err = parseDelete(c)
+	case "replace":
+		op, err = parseReplace(c)
+	case "layout":
+		op, err = parseLayout(c)
+	case "velocity":
+		if c.keyword("random") {
+			op, err = parseRandomVelocity(c)
+			break
+		}
+		var (
+			mt     motionTarget
+			values []float32
+		)
+		if mt, values, err = parseMotion(c); err == nil {
+			op = painter.Velocity{ID: mt.id, Group: mt.group, VX: values[0], VY: values[1]}
+		}
+	case "accel":
+		var (
+			mt     motionTarget
+			values []float32
+		)
+		if mt, values, err = parseMotion(c); err == nil {
+			op = painter.Acceleration{ID: mt.id, Group: mt.group, AX: values[0], AY: values[1]}
+		}
+	case "simulate":
+		op, err = parseSimulate(c)
+	case "fill":
+		op, err = parseFill(c)
+	case "filter":
+		op, err = parseFilterCommand(c)
+	case "clip":
+		op, err = parseClip(c)
+	case "unclip":
+		op = painter.PopClip{All: c.keyword("all")}
+	case "stroke":
+		op, err = parseStroke(c)
+	case "shadow":
+		op, err = parseShadow(c)
+	case "glow":
+		op, err = parseGlow(c)
+	case "hollow", "solid":
+		var tg target
+		if tg, err = parseTarget(c); err == nil {
+			op = painter.SetHollow{Rect: tg.rect, ID: tg.id, Group: tg.group, Hollow: c.name.text == "hollow"}
+		}
+	case "random":
+		op, err = parseRandom(c)
+	case "scatter":
+		op, err = parseScatter(c)
+	case "jitter":
+		op, err = parseJitter(c)
+	default:
+		return nil, c.errorAt(-1, "unknown command", "")
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return op, c.end()
+}
+
+// parseLet розбирає команду "let <змінна> = <вираз>", що задає змінну для виразів у наступних командах.
+// Змінну можна перевизначити, у тому числі через її попереднє значення.
+func parseLet(c *command) error {
+	name, err := c.next("variable name")
+	if err != nil {
+		return err
+	}
+	if !isVariable(name) {
+		return c.errorAt(c.pos-1, "invalid params", "variable name")
+	}
+	if _, err := c.oneOf("="); err != nil {
+		return err
+	}
+	v, err := c.expr("number")
+	if err != nil {
+		return err
+	}
+	c.vars[name] = v
+	return nil
+}
+
+// parseFigure розбирає команду "figure <x> <y> [scale=<множник>] [color=<колір>]".
+func parseFigure(c *command) (painter.Operation, error) {
+	coords, err := c.coords(2)
+	if err != nil {
+		return nil, err
+	}
+	fig := painter.Figure{X: coords[0], Y: coords[1]}
+
+	for {
+		if value, ok := c.option("scale"); ok {
+			v, err := evalExpr(value, c.vars)
+			if err != nil || v <= 0 {
+				return nil, c.errorAt(c.pos-1, "invalid params", "scale=<positive number>")
+			}
+			fig.Scale = float32(v)
+		} else if value, ok := c.option("color"); ok {
+			if fig.Color, err = parseColor(value); err != nil {
+				return nil, c.errorAt(c.pos-1, "invalid color", "color=<color>")
+			}
+		} else {
+			return fig, nil
+		}
+	}
+}
+
+// parseMove розбирає команду "move [group <назва>] <x> <y>".
+func parseMove(c *command) (painter.Operation, error) {
+	var group string
+	if c.keyword("group") {
+		var err error
+		if group, err = c.groupName(); err != nil {
 			return nil, err
 		}
-		return painter.BgRect{
-			X1: params[0],
-			Y1: params[1],
-			X2: params[2],
-			Y2: params[3],
-		}, nil
+	}
+
+	coords, err := c.coords(2)
+	if err != nil {
+		return nil, err
+	}
+	if group != "" {
+		return painter.GroupMove{Name: group, X: coords[0], Y: coords[1]}, nil
+	}
+	return painter.Move{X: coords[0], Y: coords[1]}, nil
+}
+
+// parseGroup розбирає команду "group <назва> <учасник>...", де учасником є ідентифікатор фігури або назва групи.
+func parseGroup(c *command) (painter.Operation, error) {
+	name, err := c.groupName()
+	if err != nil {
+		return nil, err
+	}
+	if !c.more() {
+		return nil, c.fail("invalid params count", "figure id or group name")
+	}
+
+	var members []string
+	for c.more() {
+		member, _ := c.next("")
+		if !isName(member) && !isID(member) {
+			return nil, c.errorAt(c.pos-1, "invalid params", "figure id or group name")
+		}
+		members = append(members, member)
+	}
+	return painter.Group{Name: name, Members: members}, nil
+}
+
+// parseScale розбирає команду "scale group <назва> <множник>".
+func parseScale(c *command) (painter.Operation, error) {
+	name, err := parseGroupTarget(c)
+	if err != nil {
+		return nil, err
+	}
+	factor, err := c.positive()
+	if err != nil {
+		return nil, err
+	}
+	return painter.GroupScale{Name: name, Factor: factor}, nil
+}
+
+// parseRecolor розбирає команду "recolor group <назва> <колір>".
+func parseRecolor(c *command) (painter.Operation, error) {
+	name, err := parseGroupTarget(c)
+	if err != nil {
+		return nil, err
+	}
+	col, err := c.color()
+	if err != nil {
+		return nil, err
+	}
+	return painter.GroupColor{Name: name, Color: col}, nil
+}
+
+// parseGroupTarget розбирає обов'язкову ціль "group <назва>".
+func parseGroupTarget(c *command) (string, error) {
+	if _, err := c.oneOf("group"); err != nil {
+		return "", err
+	}
+	return c.groupName()
+}
+
+// parseDelete розбирає команди виду "delete figure <id>", "delete index <n>", "delete at <x> <y>",
+// "delete rect" та "delete group <назва>".
+func parseDelete(c *command) (painter.Operation, error) {
+	kind, err := c.oneOf("figure", "index", "at", "rect", "group")
+	if err != nil {
+		return nil, err
+	}
+
+	switch kind {
 	case "figure":
-		params, err := parseParams(commandParams, 2)
+		id, err := c.id()
 		if err != nil {
 			return nil, err
 		}
-		return painter.Figure{X: params[0], Y: params[1]}, nil
-	case "move":
-		params, err := parseParams(commandParams, 2)
+		return painter.DeleteFigure{ID: id}, nil
+	case "index":
+		index, err := c.integerFrom(0)
 		if err != nil {
 			return nil, err
 		}
-		return painter.Move{X: params[0], Y: params[1]}, nil
-	case "reset":
-		return painter.ResetOp, nil
+		return painter.DeleteIndex{Index: index}, nil
+	case "at":
+		coords, err := c.coords(2)
+		if err != nil {
+			return nil, err
+		}
+		return painter.DeleteAt{X: coords[0], Y: coords[1]}, nil
+	case "rect":
+		return painter.DeleteRect{}, nil
 	default:
-		return nil, errors.New("unknown command")
+		name, err := c.groupName()
+		if err != nil {
+			return nil, err
+		}
+		return painter.GroupDelete{Name: name}, nil
 	}
 }
 
-func parseParams(params []string, length int) ([]float32, error) {
-	var res []float32
+// parseReplace розбирає команди виду "replace figure <id> <x> <y> [scale=<n>] [color=<c>]" та
+// "replace rect <x1> <y1> <x2> <y2>". Фігура отримує усі параметри з команди, як у команді figure.
+func parseReplace(c *command) (painter.Operation, error) {
+	kind, err := c.oneOf("figure", "rect")
+	if err != nil {
+		return nil, err
+	}
 
-	if len(params) != length {
-		return nil, errors.New("invalid params count")
+	if kind == "figure" {
+		id, err := c.id()
+		if err != nil {
+			return nil, err
+		}
+		fig, err := parseFigure(c)
+		if err != nil {
+			return nil, err
+		}
+		return painter.ReplaceFigure{ID: id, Figure: fig.(painter.Figure)}, nil
 	}
 
-	for _, item := range params {
-		floatNum, err := strconv.ParseFloat(item, 32)
+	coords, err := c.coords(4)
+	if err != nil {
+		return nil, err
+	}
+	return painter.ReplaceRect{Rect: painter.BgRect{X1: coords[0], Y1: coords[1], X2: coords[2], Y2: coords[3]}}, nil
+}
+
+// parseLayout розбирає команди виду "layout grid <rows> <cols> [margin]", "layout line <x1> <y1> <x2> <y2>"
+// та "layout circle <x> <y> <r>". Кожна з них може закінчуватись на "group <назва>".
+func parseLayout(c *command) (painter.Operation, error) {
+	kind, err := c.oneOf("grid", "line", "circle")
+	if err != nil {
+		return nil, err
+	}
+
+	var op painter.Operation
+	switch kind {
+	case "grid":
+		grid := painter.LayoutGrid{}
+		if grid.Rows, err = c.integerFrom(1); err != nil {
+			return nil, err
+		}
+		if grid.Cols, err = c.integerFrom(1); err != nil {
+			return nil, err
+		}
+		if c.more() && c.peek() != "group" {
+			if grid.Margin, err = c.coord(); err != nil {
+				return nil, err
+			}
+			if grid.Margin >= 0.5 {
+				return nil, c.exprError("invalid params", "margin in [0, 0.5)")
+			}
+		}
+		op = grid
+	case "line":
+		values, err := c.coords(4)
 		if err != nil {
-			return nil, errors.New("invalid params")
+			return nil, err
 		}
+		op = painter.LayoutLine{X1: values[0], Y1: values[1], X2: values[2], Y2: values[3]}
+	default:
+		values, err := c.coords(3)
+		if err != nil {
+			return nil, err
+		}
+		op = painter.LayoutCircle{X: values[0], Y: values[1], R: values[2]}
+	}
+
+	if !c.keyword("group") {
+		return op, nil
+	}
+	group, err := c.groupName()
+	if err != nil {
+		return nil, err
+	}
+	switch l := op.(type) {
+	case painter.LayoutGrid:
+		l.Group = group
+		op = l
+	case painter.LayoutLine:
+		l.Group = group
+		op = l
+	case painter.LayoutCircle:
+		l.Group = group
+		op = l
+	}
+	return op, nil
+}
+
+// motionTarget фігура або група, до якої застосовується команда руху. Порожня ціль означає усі фігури.
+type motionTarget struct {
+	id    int
+	group string
+}
 
-		if floatNum < 0 || floatNum > 1 {
-			return nil, errors.New("invalid coordinates")
+// parseMotion розбирає параметри команд виду "velocity [figure <id> | group <назва>] <x> <y>".
+func parseMotion(c *command) (motionTarget, []float32, error) {
+	var (
+		mt  motionTarget
+		err error
+	)
+	switch {
+	case c.keyword("figure"):
+		mt.id, err = c.id()
+	case c.keyword("group"):
+		mt.group, err = c.groupName()
+	}
+	if err != nil {
+		return mt, nil, err
+	}
+
+	values := make([]float32, 2)
+	for i := range values {
+		if values[i], err = c.number(); err != nil {
+			return mt, nil, err
 		}
+	}
+	return mt, values, nil
+}
 
-		res = append(res, float32(floatNum))
+// parseRandomVelocity розбирає параметри команди "velocity random <max> [seed=<n>]".
+func parseRandomVelocity(c *command) (painter.Operation, error) {
+	max, err := c.number()
+	if err != nil {
+		return nil, err
+	}
+	if max < 0 {
+		return nil, c.exprError("invalid params", "non-negative number")
 	}
 
-	return res, nil
+	seed, _, err := c.seed()
+	if err != nil {
+		return nil, err
+	}
+	return painter.RandomVelocity{Max: max, Seed: seed}, nil
+}
+
+// parseSimulate розбирає команди "simulate on [collide]" та "simulate off".
+func parseSimulate(c *command) (painter.Operation, error) {
+	mode, err := c.oneOf("on", "off")
+	if err != nil {
+		return nil, err
+	}
+	if mode == "off" {
+		return painter.Simulate{}, nil
+	}
+	return painter.Simulate{Enabled: true, Collisions: c.keyword("collide")}, nil
+}
+
+// parseClip розбирає команди "clip rect <x1> <y1> <x2> <y2>", "clip circle <x> <y> <r>" та "clip bgrect".
+// Область відсікає прямокутник та фігури, додані після неї і до відповідного unclip.
+func parseClip(c *command) (painter.Operation, error) {
+	kind, err := c.oneOf("rect", "circle", "bgrect")
+	if err != nil {
+		return nil, err
+	}
+
+	switch kind {
+	case "rect":
+		values, err := c.coords(4)
+		if err != nil {
+			return nil, err
+		}
+		return painter.PushClip{Clip: painter.ClipRect{X1: values[0], Y1: values[1], X2: values[2], Y2: values[3]}}, nil
+	case "circle":
+		values, err := c.coords(3)
+		if err != nil {
+			return nil, err
+		}
+		return painter.PushClip{Clip: painter.ClipCircle{X: values[0], Y: values[1], R: values[2]}}, nil
+	default:
+		return painter.PushClip{Clip: painter.ClipBgRect{}}, nil
+	}
+}
+
+// target ціль команди оформлення: прямокутник сцени, фігура, група або, якщо нічого не вказано, усі фігури.
+type target struct {
+	rect  bool
+	id    int
+	group string
+}
+
+// parseTarget розбирає необов'язкову ціль "rect", "figure <id>" або "group <назва>".
+func parseTarget(c *command) (target, error) {
+	var (
+		tg  target
+		err error
+	)
+	switch {
+	case c.keyword("rect"):
+		tg.rect = true
+	case c.keyword("figure"):
+		tg.id, err = c.id()
+	case c.keyword("group"):
+		tg.group, err = c.groupName()
+	}
+	return tg, err
+}
+
+// parseStroke розбирає команди "stroke [ціль] <колір> <товщина> [dash=<a>,<b>,...]" та "stroke [ціль] none".
+func parseStroke(c *command) (painter.Operation, error) {
+	tg, err := parseTarget(c)
+	if err != nil {
+		return nil, err
+	}
+	op := painter.SetStroke{Rect: tg.rect, ID: tg.id, Group: tg.group}
+	if c.keyword("none") {
+		return op, nil
+	}
+
+	col, err := c.color()
+	if err != nil {
+		return nil, err
+	}
+	width, err := c.integerFrom(1)
+	if err != nil {
+		return nil, err
+	}
+	op.Stroke = &painter.Stroke{Color: col, Width: width}
+
+	if value, ok := c.option("dash"); ok {
+		for _, item := range strings.Split(value, ",") {
+			d, err := strconv.Atoi(item)
+			if err != nil || d < 1 {
+				return nil, c.errorAt(c.pos-1, "invalid params", "dash=<length>,<length>,... with positive lengths")
+			}
+			op.Stroke.Dash = append(op.Stroke.Dash, d)
+		}
+	}
+
+	return op, nil
+}
+
+// parseShadow розбирає команди "shadow [ціль] <dx> <dy> <розмиття> <колір>" та "shadow [ціль] none".
+// Зсув та розмиття задаються у пікселях, розмиття не більше за painter.MaxEffectRadius.
+func parseShadow(c *command) (painter.Operation, error) {
+	tg, err := parseTarget(c)
+	if err != nil {
+		return nil, err
+	}
+	op := painter.SetShadow{Rect: tg.rect, ID: tg.id, Group: tg.group}
+	if c.keyword("none") {
+		return op, nil
+	}
+
+	shadow := &painter.Shadow{}
+	if shadow.DX, err = c.integer(); err != nil {
+		return nil, err
+	}
+	if shadow.DY, err = c.integer(); err != nil {
+		return nil, err
+	}
+	if shadow.Blur, err = c.integerIn(0, painter.MaxEffectRadius); err != nil {
+		return nil, err
+	}
+	if shadow.Color, err = c.color(); err != nil {
+		return nil, err
+	}
+
+	op.Shadow = shadow
+	return op, nil
+}
+
+// parseGlow розбирає команди "glow [ціль] <радіус> <колір>" та "glow [ціль] none".
+// Радіус задається у пікселях і не більший за painter.MaxEffectRadius.
+func parseGlow(c *command) (painter.Operation, error) {
+	tg, err := parseTarget(c)
+	if err != nil {
+		return nil, err
+	}
+	op := painter.SetGlow{Rect: tg.rect, ID: tg.id, Group: tg.group}
+	if c.keyword("none") {
+		return op, nil
+	}
+
+	glow := &painter.Glow{}
+	if glow.Radius, err = c.integerIn(1, painter.MaxEffectRadius); err != nil {
+		return nil, err
+	}
+	if glow.Color, err = c.color(); err != nil {
+		return nil, err
+	}
+
+	op.Glow = glow
+	return op, nil
+}
+
+// isName перевіряє, чи рядок може бути назвою групи: літера, за якою йдуть літери, цифри, '_' або '-'.
+func isName(s string) bool {
+	for i, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_':
+		case i > 0 && (r >= '0' && r <= '9' || r == '-'):
+		default:
+			return false
+		}
+	}
+	return s != ""
+}
+
+// isID перевіряє, чи рядок є ідентифікатором фігури.
+func isID(s string) bool {
+	id, err := strconv.Atoi(s)
+	return err == nil && id > 0
+}
+
+var namedColors = map[string]color.Color{
+	"white":  color.White,
+	"black":  color.Black,
+	"green":  color.RGBA{G: 0xff, A: 0xff},
+	"red":    color.RGBA{R: 0xff, A: 0xff},
+	"blue":   color.RGBA{B: 0xff, A: 0xff},
+	"yellow": color.RGBA{R: 0xff, G: 0xff, A: 0xff},
+}
+
+// parseColor розбирає колір, заданий назвою або у форматі #rgb, #rrggbb чи #rrggbbaa.
+func parseColor(param string) (color.Color, error) {
+	if c, ok := namedColors[param]; ok {
+		return c, nil
+	}
+
+	hex := strings.TrimPrefix(param, "#")
+	if len(hex) == len(param) {
+		return nil, errors.New("invalid color")
+	}
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+	if len(hex) != 8 {
+		return nil, errors.New("invalid color")
+	}
+
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return nil, errors.New("invalid color")
+	}
+	return color.NRGBA{R: uint8(v >> 24), G: uint8(v >> 16), B: uint8(v >> 8), A: uint8(v)}, nil
 }
@@ -1,11 +1,14 @@
 package lang
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/roman-mazur/architecture-lab-3/painter"
 	"github.com/stretchr/testify/assert"
 	"image/color"
 	"io"
+	"math"
 	"strings"
 	"testing"
 )
@@ -66,6 +69,941 @@ func TestParser_Parse(t *testing.T) {
 	_, wrongErr := parser.Parse(wrongCmd)
 
 	if assert.NotNil(t, wrongErr) {
-		assert.Equal(t, errors.New("unknown command"), wrongErr)
+		assert.Equal(t, Diagnostics{{
+			Line:    1,
+			Column:  1,
+			Command: "some",
+			Arg:     -1,
+			Got:     `"some"`,
+			Message: "unknown command",
+			Source:  "some wrong command",
+		}}, wrongErr)
 	}
 }
+
+func TestParser_ParseGroups(t *testing.T) {
+	parser := Parser{}
+
+	res, err := parser.Parse(strings.NewReader(`group pair 1 2
+group all pair 3
+move group all 0.5 0.5
+scale group pair 1.5
+recolor group pair #ff0
+delete group all`))
+
+	if assert.Nil(t, err) {
+		assert.Equal(t, []painter.Operation{
+			painter.Group{Name: "pair", Members: []string{"1", "2"}},
+			painter.Group{Name: "all", Members: []string{"pair", "3"}},
+			painter.GroupMove{Name: "all", X: 0.5, Y: 0.5},
+			painter.GroupScale{Name: "pair", Factor: 1.5},
+			painter.GroupColor{Name: "pair", Color: color.NRGBA{R: 0xff, G: 0xff, A: 0xff}},
+			painter.GroupDelete{Name: "all"},
+		}, res)
+	}
+
+	_, err = parser.Parse(strings.NewReader("group 1 2"))
+	assert.NotNil(t, err)
+
+	_, err = parser.Parse(strings.NewReader("recolor group pair purple"))
+	var parseErr *ParseError
+	if assert.True(t, errors.As(err, &parseErr)) {
+		assert.Equal(t, "invalid color", parseErr.Message)
+		assert.Equal(t, 2, parseErr.Arg)
+	}
+}
+
+func TestParser_ParseErrorPosition(t *testing.T) {
+	parser := Parser{}
+
+	res, err := parser.Parse(strings.NewReader("white\nfigure 0.5 0.5\nmove 0.2  1.5\nupdate"))
+	assert.Len(t, res, 3)
+
+	var parseErr *ParseError
+	if assert.True(t, errors.As(err, &parseErr)) {
+		assert.Equal(t, 3, parseErr.Line)
+		assert.Equal(t, 11, parseErr.Column)
+		assert.Equal(t, "move", parseErr.Command)
+		assert.Equal(t, 1, parseErr.Arg)
+		assert.Equal(t, "number in [0, 1]", parseErr.Expected)
+		assert.Equal(t, `"1.5"`, parseErr.Got)
+		assert.Equal(t, `line 3, column 11: move: invalid coordinates: expected number in [0, 1], got "1.5"`, err.Error())
+		assert.Equal(t, "move 0.2  1.5\n          ^", parseErr.Caret())
+	}
+
+	_, err = parser.Parse(strings.NewReader("bgrect 0.1 0.1 0.9"))
+	if assert.True(t, errors.As(err, &parseErr)) {
+		assert.Equal(t, 3, parseErr.Arg)
+		assert.Equal(t, 20, parseErr.Column)
+		assert.Equal(t, "end of command", parseErr.Got)
+	}
+
+	_, err = parser.Parse(strings.NewReader("figure 0.5 0.5 0.5"))
+	if assert.True(t, errors.As(err, &parseErr)) {
+		assert.Equal(t, "invalid params count", parseErr.Message)
+		assert.Equal(t, 2, parseErr.Arg)
+		assert.Equal(t, "end of command", parseErr.Expected)
+	}
+}
+
+func TestParser_ParseDelete(t *testing.T) {
+	parser := Parser{}
+
+	res, err := parser.Parse(strings.NewReader(`delete figure 3
+delete index 0
+delete at 0.5 0.25
+delete rect
+replace figure 2 0.1 0.9 scale=2 color=red
+replace rect 0.1 0.2 0.3 0.4`))
+
+	if assert.Nil(t, err) {
+		assert.Equal(t, []painter.Operation{
+			painter.DeleteFigure{ID: 3},
+			painter.DeleteIndex{Index: 0},
+			painter.DeleteAt{X: 0.5, Y: 0.25},
+			painter.DeleteRect{},
+			painter.ReplaceFigure{ID: 2, Figure: painter.Figure{X: 0.1, Y: 0.9, Scale: 2, Color: color.RGBA{R: 0xff, A: 0xff}}},
+			painter.ReplaceRect{Rect: painter.BgRect{X1: 0.1, Y1: 0.2, X2: 0.3, Y2: 0.4}},
+		}, res)
+	}
+
+	_, err = parser.Parse(strings.NewReader("delete figure 0"))
+	assert.NotNil(t, err)
+
+	_, err = parser.Parse(strings.NewReader("delete"))
+	assert.NotNil(t, err)
+}
+
+func TestParser_ParseLayout(t *testing.T) {
+	parser := Parser{}
+
+	res, err := parser.Parse(strings.NewReader(`layout grid 2 3
+layout grid 2 2 0.1 group row
+layout line 0.1 0.5 0.9 0.5
+layout circle 0.5 0.5 0.3 group ring`))
+
+	if assert.Nil(t, err) {
+		assert.Equal(t, []painter.Operation{
+			painter.LayoutGrid{Rows: 2, Cols: 3},
+			painter.LayoutGrid{Group: "row", Rows: 2, Cols: 2, Margin: 0.1},
+			painter.LayoutLine{X1: 0.1, Y1: 0.5, X2: 0.9, Y2: 0.5},
+			painter.LayoutCircle{Group: "ring", X: 0.5, Y: 0.5, R: 0.3},
+		}, res)
+	}
+
+	_, err = parser.Parse(strings.NewReader("layout grid 0 2"))
+	assert.NotNil(t, err)
+}
+
+func TestParser_ParseMotion(t *testing.T) {
+	parser := Parser{}
+
+	res, err := parser.Parse(strings.NewReader(`velocity 0.1 -0.2
+velocity figure 2 -0.5 0
+accel group g 0 0.98
+velocity random 0.3 seed=42
+simulate on collide
+simulate off`))
+
+	if assert.Nil(t, err) {
+		assert.Equal(t, []painter.Operation{
+			painter.Velocity{VX: 0.1, VY: -0.2},
+			painter.Velocity{ID: 2, VX: -0.5},
+			painter.Acceleration{Group: "g", AY: 0.98},
+			painter.RandomVelocity{Max: 0.3, Seed: 42},
+			painter.Simulate{Enabled: true, Collisions: true},
+			painter.Simulate{},
+		}, res)
+	}
+
+	_, err = parser.Parse(strings.NewReader("velocity 1e39 0"))
+	assert.NotNil(t, err)
+
+	_, err = parser.Parse(strings.NewReader("velocity random 0.3 seed=x"))
+	assert.NotNil(t, err)
+}
+
+func TestParser_ParseFill(t *testing.T) {
+	parser := Parser{}
+
+	res, err := parser.Parse(strings.NewReader(`fill linear 45 white@1 black@0
+fill rect radial 0.5 0.5 red green blue
+fill figure 1 checker 0.1 white black
+fill group g stripes 0.05 90 #000 #fff`))
+
+	white := color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+	black := color.NRGBA{A: 0xff}
+	if assert.Nil(t, err) {
+		assert.Equal(t, []painter.Operation{
+			painter.PatternFill{Pattern: &painter.LinearGradient{Angle: 45, Stops: []painter.Stop{
+				{Offset: 0, Color: color.Black}, {Offset: 1, Color: color.White},
+			}}},
+			painter.RectFill{Pattern: &painter.RadialGradient{CX: 0.5, CY: 0.5, Stops: []painter.Stop{
+				{Offset: 0, Color: color.RGBA{R: 0xff, A: 0xff}},
+				{Offset: 0.5, Color: color.RGBA{G: 0xff, A: 0xff}},
+				{Offset: 1, Color: color.RGBA{B: 0xff, A: 0xff}},
+			}}},
+			painter.FigureFill{ID: 1, Pattern: &painter.Checkerboard{Size: 0.1, A: color.White, B: color.Black}},
+			painter.FigureFill{Group: "g", Pattern: &painter.Stripes{Width: 0.05, Angle: 90, A: black, B: white}},
+		}, res)
+	}
+
+	_, err = parser.Parse(strings.NewReader("fill linear 0 white"))
+	assert.NotNil(t, err)
+}
+
+func TestParser_ParseFilter(t *testing.T) {
+	parser := Parser{}
+
+	res, err := parser.Parse(strings.NewReader(`filter grayscale
+filter blur 3
+filter brightness -0.2
+filter colorblind deuteranopia
+filter clear`))
+
+	if assert.Nil(t, err) {
+		assert.Equal(t, []painter.Operation{
+			painter.AddFilter{Filter: painter.Grayscale{}},
+			painter.AddFilter{Filter: painter.Blur{Radius: 3}},
+			painter.AddFilter{Filter: painter.Brightness{Amount: -0.2}},
+			painter.AddFilter{Filter: painter.ColorBlind{Kind: "deuteranopia"}},
+			painter.SetFilters{},
+		}, res)
+	}
+
+	filters, err := ParseFilters(strings.NewReader("invert\n\npixelate 8\n"))
+	if assert.Nil(t, err) {
+		assert.Equal(t, []painter.Filter{painter.Invert{}, painter.Pixelate{Size: 8}}, filters)
+	}
+
+	_, err = parser.Parse(strings.NewReader("filter colorblind achromatopsia"))
+	assert.NotNil(t, err)
+}
+
+func TestParser_ParseClip(t *testing.T) {
+	parser := Parser{}
+
+	res, err := parser.Parse(strings.NewReader(`clip rect 0.1 0.1 0.9 0.9
+clip circle 0.5 0.5 0.25
+clip bgrect
+unclip
+unclip all`))
+
+	if assert.Nil(t, err) {
+		assert.Equal(t, []painter.Operation{
+			painter.PushClip{Clip: painter.ClipRect{X1: 0.1, Y1: 0.1, X2: 0.9, Y2: 0.9}},
+			painter.PushClip{Clip: painter.ClipCircle{X: 0.5, Y: 0.5, R: 0.25}},
+			painter.PushClip{Clip: painter.ClipBgRect{}},
+			painter.PopClip{},
+			painter.PopClip{All: true},
+		}, res)
+	}
+}
+
+func TestParser_ParseStroke(t *testing.T) {
+	parser := Parser{}
+
+	res, err := parser.Parse(strings.NewReader(`stroke rect black 3 dash=6,3
+stroke figure 2 red 1
+stroke group g none
+hollow rect
+solid`))
+
+	if assert.Nil(t, err) {
+		assert.Equal(t, []painter.Operation{
+			painter.SetStroke{Rect: true, Stroke: &painter.Stroke{Color: color.Black, Width: 3, Dash: []int{6, 3}}},
+			painter.SetStroke{ID: 2, Stroke: &painter.Stroke{Color: color.RGBA{R: 0xff, A: 0xff}, Width: 1}},
+			painter.SetStroke{Group: "g"},
+			painter.SetHollow{Rect: true, Hollow: true},
+			painter.SetHollow{},
+		}, res)
+	}
+
+	_, err = parser.Parse(strings.NewReader("stroke black 2 dash=0"))
+	assert.NotNil(t, err)
+}
+
+func TestParser_ParseEffects(t *testing.T) {
+	parser := Parser{}
+
+	res, err := parser.Parse(strings.NewReader(`shadow 5 -5 3 #00000080
+shadow rect none
+glow group g 8 yellow
+glow figure 1 none`))
+
+	if assert.Nil(t, err) {
+		assert.Equal(t, []painter.Operation{
+			painter.SetShadow{Shadow: &painter.Shadow{DX: 5, DY: -5, Blur: 3, Color: color.NRGBA{A: 0x80}}},
+			painter.SetShadow{Rect: true},
+			painter.SetGlow{Group: "g", Glow: &painter.Glow{Radius: 8, Color: color.RGBA{R: 0xff, G: 0xff, A: 0xff}}},
+			painter.SetGlow{ID: 1},
+		}, res)
+	}
+
+	_, err = parser.Parse(strings.NewReader("glow 0 red"))
+	assert.NotNil(t, err)
+	_, err = parser.Parse(strings.NewReader("glow 101 red"))
+	assert.NotNil(t, err)
+	_, err = parser.Parse(strings.NewReader("shadow 0 0 100000 black"))
+	assert.NotNil(t, err)
+}
+
+func TestParser_ParseRandom(t *testing.T) {
+	parser := Parser{}
+
+	res, err := parser.Parse(strings.NewReader(`random figures 50 seed=42
+random figures 5 in 0.1 0.1 0.5 0.5 colors
+random rect seed=1
+random colors group g seed=2
+scatter group g in 0.2 0.2 0.8 0.8 seed=3
+jitter 0.05 seed=4`))
+
+	if assert.Nil(t, err) {
+		assert.Equal(t, []painter.Operation{
+			painter.RandomFigures{Count: 50, Area: painter.FullArea, Seed: 42},
+			painter.RandomFigures{Count: 5, Area: painter.Area{X1: 0.1, Y1: 0.1, X2: 0.5, Y2: 0.5}, Colors: true},
+			painter.RandomRect{Area: painter.FullArea, Seed: 1},
+			painter.RandomColors{Group: "g", Seed: 2},
+			painter.Scatter{Group: "g", Area: painter.Area{X1: 0.2, Y1: 0.2, X2: 0.8, Y2: 0.8}, Seed: 3},
+			painter.Jitter{Amount: 0.05, Seed: 4},
+		}, res)
+	}
+
+	_, err = parser.Parse(strings.NewReader("random figures 100000"))
+	assert.NotNil(t, err)
+
+	_, err = parser.Parse(strings.NewReader("random rect colors"))
+	assert.NotNil(t, err)
+}
+
+func TestParser_ParseComments(t *testing.T) {
+	parser := Parser{}
+
+	res, err := parser.Parse(strings.NewReader("# Сцена\r\n\r\nwhite\r\n   \r\n" +
+		"figure 0.5 0.5 // центр\r\n" +
+		"recolor group g #ff0 # жовтий\r\n" +
+		"move 0.1 0.1; update; #note\r\n" +
+		"white #note\r\n" +
+		"\t// кінець"))
+
+	if assert.Nil(t, err) {
+		assert.Equal(t, []painter.Operation{
+			painter.Fill{Color: color.White},
+			painter.Figure{X: 0.5, Y: 0.5},
+			painter.GroupColor{Name: "g", Color: color.NRGBA{R: 0xff, G: 0xff, A: 0xff}},
+			painter.Move{X: 0.1, Y: 0.1},
+			painter.UpdateOp,
+			painter.Fill{Color: color.White},
+		}, res)
+	}
+
+	res, err = parser.Parse(strings.NewReader("fill linear 0 #f00@0.5 blue #gradient"))
+	assert.Nil(t, err)
+	assert.Len(t, res, 1)
+
+	_, err = parser.Parse(strings.NewReader("white #add"))
+	var countErr *ParseError
+	if assert.True(t, errors.As(err, &countErr)) {
+		assert.Equal(t, `end of command or "# <comment>"`, countErr.Expected)
+	}
+
+	res, err = parser.Parse(strings.NewReader(""))
+	assert.Nil(t, err)
+	assert.Empty(t, res)
+
+	_, err = parser.Parse(strings.NewReader("white; figure 0.5 2"))
+	var parseErr *ParseError
+	if assert.True(t, errors.As(err, &parseErr)) {
+		assert.Equal(t, "figure", parseErr.Command)
+		assert.Equal(t, 19, parseErr.Column)
+	}
+}
+
+func TestParser_ParseDiagnostics(t *testing.T) {
+	parser := Parser{}
+
+	res, diags := parser.ParseDiagnostics(strings.NewReader(`white
+figure 1.5 0.5
+blink
+figure 0.5 0.5; move 0.1
+update`))
+
+	assert.Equal(t, []painter.Operation{
+		painter.Fill{Color: color.White},
+		painter.Figure{X: 0.5, Y: 0.5},
+		painter.UpdateOp,
+	}, res)
+
+	if assert.Len(t, diags, 3) {
+		assert.Equal(t, 2, diags[0].Line)
+		assert.Equal(t, "invalid coordinates", diags[0].Message)
+		assert.Equal(t, 3, diags[1].Line)
+		assert.Equal(t, "unknown command", diags[1].Message)
+		assert.Equal(t, 4, diags[2].Line)
+		assert.Equal(t, "move", diags[2].Command)
+		for _, d := range diags {
+			assert.Equal(t, SeverityError, d.Severity)
+		}
+	}
+	assert.NotNil(t, diags.Err())
+
+	_, err := parser.Parse(strings.NewReader(strings.Repeat("blink\n", 2*maxDiagnostics)))
+	var all Diagnostics
+	if assert.True(t, errors.As(err, &all)) {
+		assert.Len(t, all, maxDiagnostics+1)
+		assert.Equal(t, "too many errors", all[maxDiagnostics].Message)
+	}
+}
+
+func TestParser_ParseExpressions(t *testing.T) {
+	parser := Parser{}
+
+	res, err := parser.Parse(strings.NewReader(`let x = 0.25
+let step = x / 2
+figure x x+step
+move x - step (x + step) * 2
+let x = x * 3
+figure min(x, 0.5) max(0, cos(90))
+shadow 5 -5 round(step * 10) black
+fill linear 45 white@0 black@x`))
+
+	if assert.Nil(t, err) {
+		assert.Equal(t, []painter.Operation{
+			painter.Figure{X: 0.25, Y: 0.375},
+			painter.Move{X: 0.125, Y: 0.75},
+			painter.Figure{X: 0.5, Y: float32(math.Max(0, math.Cos(math.Pi/2)))},
+			painter.SetShadow{Shadow: &painter.Shadow{DX: 5, DY: -5, Blur: 1, Color: color.Black}},
+			painter.PatternFill{Pattern: &painter.LinearGradient{Angle: 45, Stops: []painter.Stop{
+				{Offset: 0, Color: color.White},
+				{Offset: 0.75, Color: color.Black},
+			}}},
+		}, res)
+	}
+
+	_, diags := parser.ParseDiagnostics(strings.NewReader(`let x = 0.75
+figure x * 2 0.5
+figure y 0.5
+bgrect 0 0 1 / 0 1`))
+	if assert.Len(t, diags, 3) {
+		assert.Equal(t, "invalid coordinates", diags[0].Message)
+		assert.Equal(t, `"x * 2" = 1.5`, diags[0].Got)
+		assert.Equal(t, `invalid expression: undefined variable "y"`, diags[1].Message)
+		assert.Equal(t, `invalid expression: division by zero`, diags[2].Message)
+		assert.Equal(t, `"1 / 0"`, diags[2].Got)
+	}
+}
+
+func TestParser_ParseLoops(t *testing.T) {
+	parser := Parser{}
+
+	res, err := parser.Parse(strings.NewReader(`let x = 0
+repeat 2 {
+	let x = x + 0.25
+	figure x 0.5
+}
+for i in 0..1 step 0.5 { move i 0; update }
+for i in 3 .. 1 step -2 {
+	repeat i { update }
+}`))
+
+	if assert.Nil(t, err) {
+		assert.Equal(t, []painter.Operation{
+			painter.Figure{X: 0.25, Y: 0.5},
+			painter.Figure{X: 0.5, Y: 0.5},
+			painter.Move{X: 0, Y: 0}, painter.UpdateOp,
+			painter.Move{X: 0.5, Y: 0}, painter.UpdateOp,
+			painter.Move{X: 1, Y: 0}, painter.UpdateOp,
+			painter.UpdateOp, painter.UpdateOp, painter.UpdateOp,
+			painter.UpdateOp,
+		}, res)
+	}
+
+	_, diags := parser.ParseDiagnostics(strings.NewReader(`for i in 0..10 {
+	figure i 0.5
+}
+repeat 2 {
+	update
+}
+}
+repeat 3 {`))
+	if assert.Len(t, diags, 3) {
+		// Помилка у тілі циклу повідомляється один раз.
+		assert.Equal(t, 2, diags[0].Line)
+		assert.Equal(t, "invalid coordinates", diags[0].Message)
+		assert.Equal(t, "unexpected '}'", diags[1].Message)
+		assert.Equal(t, "unclosed block", diags[2].Message)
+		assert.Equal(t, 8, diags[2].Line)
+	}
+
+	_, err = parser.Parse(strings.NewReader("repeat 1000 { repeat 1000 { update } }"))
+	var parseErr *ParseError
+	if assert.True(t, errors.As(err, &parseErr)) {
+		assert.Equal(t, "script expands to too many operations", parseErr.Message)
+	}
+
+	_, err = parser.Parse(strings.NewReader("repeat 49000 {\nrandom figures 10000\n}\nupdate"))
+	if assert.True(t, errors.As(err, &parseErr)) {
+		assert.Equal(t, "script adds too many figures", parseErr.Message)
+		assert.Equal(t, 2, parseErr.Line)
+	}
+}
+
+func TestParser_ParseProcs(t *testing.T) {
+	parser := Parser{}
+
+	res, err := parser.Parse(strings.NewReader(`let y = 0.5
+proc pair(x, d) {
+	let x = x + d
+	figure x - d y
+	figure x y
+}
+pair(0.25, 0.1)
+pair (0.5, y / 5)
+figure x 0.5`))
+	var parseErr *ParseError
+	if assert.True(t, errors.As(err, &parseErr)) {
+		// Змінна x є локальною для процедури.
+		assert.Equal(t, 9, parseErr.Line)
+		assert.Equal(t, `invalid expression: undefined variable "x"`, parseErr.Message)
+	}
+	assert.Equal(t, []painter.Operation{
+		painter.Figure{X: 0.25, Y: 0.5},
+		painter.Figure{X: 0.35, Y: 0.5},
+		painter.Figure{X: 0.5, Y: 0.5},
+		painter.Figure{X: 0.6, Y: 0.5},
+	}, res)
+
+	procs, err := parser.ParseProcs(strings.NewReader(`proc frame() {
+	white
+	bgrect 0.25 0.25 0.75 0.75
+}
+proc dot(x) { figure x x }`))
+	if assert.Nil(t, err) && assert.Len(t, procs, 2) {
+		assert.Equal(t, "frame", procs[0].Name)
+		assert.Equal(t, "proc frame() {\n\twhite\n\tbgrect 0.25 0.25 0.75 0.75\n}", procs[0].Source)
+		assert.Equal(t, []string{"x"}, procs[1].Params)
+	}
+
+	res, err = parser.Parse(strings.NewReader("frame()\ndot(0.5)\nupdate"))
+	if assert.Nil(t, err) {
+		assert.Equal(t, []painter.Operation{
+			painter.Fill{Color: color.White},
+			painter.BgRect{X1: 0.25, Y1: 0.25, X2: 0.75, Y2: 0.75},
+			painter.Figure{X: 0.5, Y: 0.5},
+			painter.UpdateOp,
+		}, res)
+	}
+
+	// Помилка у спільній процедурі повідомляється у місці виклику.
+	_, err = parser.Parse(strings.NewReader("white\ndot(2)"))
+	if assert.True(t, errors.As(err, &parseErr)) {
+		assert.Equal(t, 2, parseErr.Line)
+		assert.Equal(t, "in procedure dot at line 5: invalid coordinates", parseErr.Message)
+	}
+
+	_, err = parser.ParseProcs(strings.NewReader("proc ok() { update }\nwhite"))
+	assert.NotNil(t, err)
+	assert.Nil(t, parser.Procs.Get("ok"))
+
+	_, err = parser.Parse(strings.NewReader("proc loop(n) { loop(n) }\nloop(1)"))
+	if assert.True(t, errors.As(err, &parseErr)) {
+		assert.Equal(t, "procedure calls nested too deeply", parseErr.Message)
+	}
+
+	_, err = parser.Parse(strings.NewReader("dot(0.1, 0.2)"))
+	assert.NotNil(t, err)
+}
+
+func TestParser_ParseIncludes(t *testing.T) {
+	lib := &Library{Dir: t.TempDir()}
+	parser := Parser{Scripts: lib}
+
+	assert.Nil(t, lib.Write("setup.paint", []byte("white\nproc dot(x) { figure x x }\nlet size = 0.5")))
+	assert.Nil(t, lib.Write("scene.paint", []byte("include \"setup.paint\"\ndot(size * k)\nupdate")))
+	assert.Nil(t, lib.Write("a.paint", []byte("include \"b.paint\"")))
+	assert.Nil(t, lib.Write("b.paint", []byte("white\ninclude \"a.paint\"")))
+	assert.NotNil(t, lib.Write("../escape.paint", nil))
+
+	names, err := lib.List()
+	if assert.Nil(t, err) {
+		assert.Equal(t, []string{"a.paint", "b.paint", "scene.paint", "setup.paint"}, names)
+	}
+
+	res, err := parser.ParseScript("scene.paint", map[string]float64{"k": 0.5})
+	if assert.Nil(t, err) {
+		assert.Equal(t, []painter.Operation{
+			painter.Fill{Color: color.White},
+			painter.Figure{X: 0.25, Y: 0.25},
+			painter.UpdateOp,
+		}, res)
+	}
+
+	_, err = parser.ParseScript("scene.paint", nil)
+	var parseErr *ParseError
+	if assert.True(t, errors.As(err, &parseErr)) {
+		assert.Equal(t, `invalid expression: undefined variable "k"`, parseErr.Message)
+	}
+
+	_, err = parser.Parse(strings.NewReader(`include "a.paint"`))
+	if assert.True(t, errors.As(err, &parseErr)) {
+		assert.Equal(t, 1, parseErr.Line)
+		assert.Equal(t, `in "b.paint" at line 2: include cycle: a.paint -> b.paint -> a.paint`, parseErr.Message)
+	}
+
+	_, err = parser.Parse(strings.NewReader(`include "missing.paint"`))
+	if assert.True(t, errors.As(err, &parseErr)) {
+		assert.Equal(t, "unknown script", parseErr.Message)
+	}
+
+	_, err = (&Parser{}).Parse(strings.NewReader(`include "setup.paint"`))
+	assert.NotNil(t, err)
+}
+
+func TestParser_ParseTemplate(t *testing.T) {
+	var parser Parser
+	const tmpl = "{{range .points}}figure {{index . 0}} {{index . 1}}\n{{end}}move {{num (mul .x 2)}} 0\nrecolor group g {{.color}}\nupdate"
+
+	res, err := parser.ParseTemplate(tmpl, map[string]any{
+		"color":  "#ff0",
+		"points": []any{[]any{0.1, 0.2}, []any{0.3, 0.4}},
+		"x":      0.15,
+	})
+	if assert.Nil(t, err) {
+		assert.Equal(t, []painter.Operation{
+			painter.Figure{X: 0.1, Y: 0.2},
+			painter.Figure{X: 0.3, Y: 0.4},
+			painter.Move{X: 0.3, Y: 0},
+			painter.GroupColor{Name: "g", Color: color.NRGBA{R: 0xff, G: 0xff, A: 0xff}},
+			painter.UpdateOp,
+		}, res)
+	}
+
+	_, err = parser.ParseTemplate(tmpl, map[string]any{"color": "#ff0\nreset", "points": nil, "x": 0})
+	assert.ErrorIs(t, err, ErrInvalidName)
+
+	_, err = parser.ParseTemplate("figure 0.5 0.5 {{.c}} bogus", map[string]any{"c": "#zz"})
+	assert.ErrorIs(t, err, ErrInvalidName)
+
+	_, err = parser.ParseTemplate(tmpl, map[string]any{"color": "white"})
+	assert.NotNil(t, err)
+
+	_, err = parser.ParseTemplate("recolor group g {{.color}}", map[string]any{"color": "nope"})
+	var parseErr *ParseError
+	if assert.True(t, errors.As(err, &parseErr)) {
+		assert.Equal(t, "invalid color", parseErr.Message)
+	}
+
+	_, err = RenderTemplate("{{range $k, $v := .m}}{{$k}}{{end}}", map[string]any{"m": map[string]any{"a\nreset": 1}})
+	assert.ErrorIs(t, err, ErrInvalidName)
+
+	for _, src := range []string{
+		"{{range 2000000000}}{{range 2000000000}}{{end}}{{end}}",
+		"{{$n := 5}}{{range $n}}{{end}}",
+		"{{range .l}}{{range $.l}}{{range $.l}}{{end}}{{end}}{{end}}",
+		`{{define "a"}}white{{end}}{{template "a"}}`,
+	} {
+		_, err = RenderTemplate(src, map[string]any{"l": make([]any, 100)})
+		assert.NotNil(t, err, src)
+	}
+}
+
+func TestFormat_RoundTrip(t *testing.T) {
+	const script = `reset
+white
+green
+fill #12345678
+fill linear 45 red@0 #00f@0.25 white@1
+fill rect radial 0.5 0.5 black yellow
+fill figure 1 checker 0.1 #fff #000
+fill group g stripes 0.05 90 blue #abc
+bgrect 0.1 0.1 0.9 0.9
+figure 0.3333 0.5
+figure 0.2 0.4 scale=1.5 color=#ff000080
+move 0.5 0.25
+group g 1 2
+group all g 3
+move group g 0.5 0.5
+scale group g 0.75
+recolor group g red
+delete group all
+delete figure 2
+delete index 0
+delete at 0.5 0.5
+delete rect
+replace figure 1 0.1 0.2 scale=1.5 color=#ff000080
+replace rect 0 0 1 1
+layout grid 2 3
+layout grid 2 3 0.1 group g
+layout line 0 0 1 1
+layout circle 0.5 0.5 0.25 group g
+velocity 0.1 -0.2
+velocity figure 1 0.1 0
+accel group g 0 0.98
+velocity random 0.5 seed=-7
+simulate on collide
+simulate on
+simulate off
+filter grayscale
+filter colorblind tritanopia
+filter blur 3
+filter clear
+clip rect 0 0 0.5 0.5
+clip circle 0.5 0.5 0.25
+clip bgrect
+unclip
+unclip all
+stroke rect #00ff00 2 dash=4,2
+stroke figure 1 black 1
+stroke none
+hollow group g
+solid
+shadow 4 -4 3 #00000080
+shadow rect none
+glow figure 1 5 yellow
+glow none
+random figures 5 in 0.1 0.1 0.9 0.9 colors seed=42
+random rect
+random colors group g seed=1
+scatter group g in 0 0 0.5 0.5 seed=3
+jitter 0.01 seed=9
+update`
+
+	var parser Parser
+	ops, err := parser.Parse(strings.NewReader(script))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	text, err := Format(ops)
+	if !assert.Nil(t, err) {
+		return
+	}
+	res, err := parser.Parse(strings.NewReader(text))
+	if assert.Nil(t, err, text) {
+		assert.Equal(t, ops, res)
+	}
+
+	again, err := Format(res)
+	assert.Nil(t, err)
+	assert.Equal(t, text, again)
+
+	_, err = Format([]painter.Operation{painter.Figure{Hollow: true}})
+	assert.NotNil(t, err)
+}
+
+func TestFormatScene(t *testing.T) {
+	var parser Parser
+	ops, err := parser.Parse(strings.NewReader(`white
+fill linear 90 red blue
+bgrect 0.2 0.2 0.8 0.8
+fill rect checker 0.1 white black
+stroke rect red 2
+shadow rect 2 2 1 black
+figure 0.1 0.1; clip rect 0 0 0.5 0.5; figure 0.2 0.2; clip bgrect; figure 0.3 0.3; figure 0.4 0.4
+unclip; figure 0.5 0.5; unclip; figure 0.6 0.6
+delete figure 2; delete figure 4
+group g 1 3
+scale group g 1.5
+recolor group g #ff000080
+hollow figure 3
+glow figure 1 4 yellow
+clip circle 0.5 0.5 0.4
+filter invert`))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	var state painter.TextureState
+	for _, op := range ops {
+		op.Update(&state)
+	}
+	scene := state.Scene()
+
+	res, err := parser.Parse(strings.NewReader(FormatScene(scene)))
+	if !assert.Nil(t, err) {
+		return
+	}
+	var restored painter.TextureState
+	for _, op := range res {
+		op.Update(&restored)
+	}
+	assert.Equal(t, scene, restored.Scene())
+}
+
+func TestFormatScript(t *testing.T) {
+	const script = `// сцена
+white ;  figure 0.50   .5 # центр
+
+
+let n = 3
+repeat n
+{
+figure 0.1 0.1; move  0.2 x*0.50
+}
+proc dot(x) {   figure x x }   //точка
+dot(0.25)
+`
+	res, err := FormatScript(strings.NewReader(script))
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, `# сцена
+white
+figure 0.5 0.5 # центр
+
+let n = 3
+repeat n {
+	figure 0.1 0.1
+	move 0.2 x*0.50
+}
+proc dot(x) {
+	figure x x
+} # точка
+dot(0.25)
+`, res)
+
+	again, err := FormatScript(strings.NewReader(res))
+	assert.Nil(t, err)
+	assert.Equal(t, res, again)
+
+	res, err = FormatScript(strings.NewReader("// /path\n## заголовок\nwhite #/ колір\n#\n"))
+	if assert.Nil(t, err) {
+		assert.Equal(t, "# /path\n# # заголовок\nwhite # / колір\n#\n", res)
+		again, err = FormatScript(strings.NewReader(res))
+		assert.Nil(t, err)
+		assert.Equal(t, res, again)
+	}
+
+	const commented = "repeat 3 # цикл\n{\nfigure 0.5 0.5\n}\n"
+	res, err = FormatScript(strings.NewReader(commented))
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, "repeat 3 # цикл\n{\n\tfigure 0.5 0.5\n}\n", res)
+
+	parser := Parser{}
+	expected, err := parser.Parse(strings.NewReader(commented))
+	assert.Nil(t, err)
+	formatted, err := parser.Parse(strings.NewReader(res))
+	assert.Nil(t, err)
+	assert.Equal(t, expected, formatted)
+}
+
+func TestParser_Lint(t *testing.T) {
+	parser := Parser{}
+
+	res, diags := parser.ParseDiagnostics(strings.NewReader(`white
+move 0.5 0.5
+fill linear 0 red blue
+bgrect 0.9 0.1 0.1 0.9
+update
+repeat 3 { figure 0.5 0.5 }
+clip rect 0.2 0.2 0.2 0.4
+update
+figure 0.1 0.1`))
+	assert.Len(t, res, 11)
+	assert.Nil(t, diags.Err())
+
+	var messages []string
+	for _, d := range diags {
+		assert.Equal(t, SeverityWarning, d.Severity)
+		messages = append(messages, fmt.Sprintf("%d: %s", d.Line, d.Message))
+	}
+	assert.Equal(t, []string{
+		"1: fill is overwritten before update",
+		"2: moves figures before the script adds any figure",
+		"4: rectangle corners are reversed: x1 > x2",
+		"7: rectangle is empty",
+		"9: no update after this command, changes are not shown",
+	}, messages)
+	assert.Equal(t, diags, diags.Warnings())
+
+	_, diags = parser.ParseDiagnostics(strings.NewReader("white\nfigure 0.5 0.5\nmove 0.2 0.2\nupdate"))
+	assert.Empty(t, diags)
+
+	_, diags = parser.ParseDiagnostics(strings.NewReader("figure 0.5 0.5\nsimulate on\nvelocity 0.1 0"))
+	assert.Empty(t, diags)
+}
+
+func TestParser_ParseJSON(t *testing.T) {
+	parser := &Parser{}
+	res, err := parser.ParseJSON(strings.NewReader(`[
+		{"op": "white"},
+		{"op": "fill", "pattern": {"type": "linear", "angle": 45, "stops": [{"color": "red"}, {"color": "#00f", "offset": 1}]}},
+		{"op": "bgrect", "x1": 0.25, "y1": 0.25, "x2": 0.75, "y2": 0.75},
+		{"op": "fill", "rect": true, "pattern": {"type": "checker", "size": 0.1, "a": "black", "b": "white"}},
+		{"op": "figure", "x": 0.5, "y": 0.5, "scale": 2, "color": "green"},
+		{"op": "randomFigures", "count": 3, "in": [0.1, 0.1, 0.9, 0.9], "colors": true, "seed": 7},
+		{"op": "group", "name": "g", "members": [1, 2]},
+		{"op": "move", "group": "g", "x": 0.1, "y": 0.2},
+		{"op": "layoutGrid", "rows": 2, "cols": 2, "margin": 0.1},
+		{"op": "stroke", "group": "g", "color": "red", "width": 2, "dash": [4, 2]},
+		{"op": "shadow", "figure": 1, "dx": 2, "dy": 3, "blur": 4, "color": "black"},
+		{"op": "deleteFigure", "id": 3},
+		{"op": "filter", "filter": "blur", "value": 2},
+		{"op": "filter", "filter": "colorblind", "value": "protanopia"},
+		{"op": "simulate", "mode": "on", "collide": true},
+		{"op": "update"}
+	]`))
+	assert.Nil(t, err)
+
+	expected, err := parser.Parse(strings.NewReader(`white
+fill linear 45 red #00f@1
+bgrect 0.25 0.25 0.75 0.75
+fill rect checker 0.1 black white
+figure 0.5 0.5 scale=2 color=green
+random figures 3 in 0.1 0.1 0.9 0.9 colors seed=7
+group g 1 2
+move group g 0.1 0.2
+layout grid 2 2 0.1
+stroke group g red 2 dash=4,2
+shadow figure 1 2 3 4 black
+delete figure 3
+filter blur 2
+filter colorblind protanopia
+simulate on collide
+update`))
+	assert.Nil(t, err)
+	assert.Equal(t, expected, res)
+
+	_, diags := parser.ParseJSONDiagnostics(strings.NewReader(`[
+		{"op": "figure", "x": 1.5, "y": 0.5},
+		{"op": "figure", "x": "a", "y": 0.5},
+		{"op": "blink"},
+		{"op": "move", "x": 0.1, "y": 0.1, "z": 1},
+		{"op": "bgrect", "x1": 0.1, "y1": 0.1, "x2": 0.9},
+		{"op": "fill", "pattern": {"type": "linear", "angle": 0, "stops": [{"color": "red"}, {"color": "nope"}]}},
+		{"op": "stroke", "figure": 1, "color": "red", "width": 2, "dash": [4, "x"]},
+		5
+	]`))
+	var paths []string
+	for _, d := range diags {
+		assert.Equal(t, SeverityError, d.Severity)
+		paths = append(paths, d.Path+": "+d.Message)
+	}
+	assert.Equal(t, []string{
+		"$[0].x: invalid coordinates",
+		"$[1].x: invalid field type",
+		"$[2].op: unknown command",
+		"$[3].z: unknown field",
+		"$[4].y2: missing field",
+		"$[5].pattern.stops[1]: invalid color",
+		"$[6].dash[1]: invalid field type",
+		"$[7]: invalid command",
+	}, paths)
+	assert.Contains(t, diags[0].Error(), "$[0].x: figure: ")
+
+	_, diags = parser.ParseJSONDiagnostics(strings.NewReader(`[{"op": "figure", "x": 0.5, "y": 0.5}]`))
+	if assert.Len(t, diags, 1) {
+		assert.Equal(t, SeverityWarning, diags[0].Severity)
+		assert.Equal(t, "$[0]", diags[0].Path)
+	}
+
+	_, err = parser.ParseJSON(strings.NewReader(`{"op": "white"}`))
+	assert.NotNil(t, err)
+
+	schema := CommandSchema()
+	assert.Len(t, schema["items"].(map[string]any)["oneOf"], len(jsonCommands))
+	_, err = json.Marshal(schema)
+	assert.Nil(t, err)
+}
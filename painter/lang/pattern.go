@@ -0,0 +1,139 @@
+package lang
+
+import (
+	"image/color"
+	"strings"
+
+	"github.com/roman-mazur/architecture-lab-3/painter"
+)
+
+// parseFill розбирає команди виду "fill [rect | figure <id> | group <назва>] <візерунок>" та "fill <колір>",
+// що зафарбовує тло кольором.
+func parseFill(c *command) (painter.Operation, error) {
+	tg, err := parseTarget(c)
+	if err != nil {
+		return nil, err
+	}
+	if tg == (target{}) && c.more() {
+		if col, err := parseColor(c.peek()); err == nil {
+			c.pos++
+			return painter.Fill{Color: col}, nil
+		}
+	}
+	pattern, err := parsePattern(c)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case tg.rect:
+		return painter.RectFill{Pattern: pattern}, nil
+	case tg.id != 0 || tg.group != "":
+		return painter.FigureFill{ID: tg.id, Group: tg.group, Pattern: pattern}, nil
+	}
+	return painter.PatternFill{Pattern: pattern}, nil
+}
+
+// parsePattern розбирає візерунок: "linear <кут> <точки...>", "radial <cx> <cy> <точки...>",
+// "checker <розмір> <колір> <колір>" або "stripes <ширина> <кут> <колір> <колір>".
+func parsePattern(c *command) (painter.Pattern, error) {
+	kind, err := c.next("pattern")
+	if err != nil {
+		return nil, err
+	}
+
+	switch kind {
+	case "linear":
+		angle, err := c.number()
+		if err != nil {
+			return nil, err
+		}
+		stops, err := parseStops(c)
+		if err != nil {
+			return nil, err
+		}
+		return &painter.LinearGradient{Angle: angle, Stops: stops}, nil
+	case "radial":
+		center, err := c.coords(2)
+		if err != nil {
+			return nil, err
+		}
+		stops, err := parseStops(c)
+		if err != nil {
+			return nil, err
+		}
+		return &painter.RadialGradient{CX: center[0], CY: center[1], Stops: stops}, nil
+	case "checker":
+		cell, err := c.coord()
+		if err != nil {
+			return nil, err
+		}
+		a, b, err := parseColorPair(c)
+		if err != nil {
+			return nil, err
+		}
+		return &painter.Checkerboard{Size: cell, A: a, B: b}, nil
+	case "stripes":
+		width, err := c.coord()
+		if err != nil {
+			return nil, err
+		}
+		angle, err := c.number()
+		if err != nil {
+			return nil, err
+		}
+		a, b, err := parseColorPair(c)
+		if err != nil {
+			return nil, err
+		}
+		return &painter.Stripes{Width: width, Angle: angle, A: a, B: b}, nil
+	default:
+		return nil, c.errorAt(c.pos-1, "unknown pattern", "one of linear, radial, checker, stripes")
+	}
+}
+
+// parseStops розбирає усі аргументи, що залишились, як опорні точки градієнта виду "<колір>@<відстань>".
+// Точки без відстані розподіляються рівномірно за своїм порядковим номером.
+func parseStops(c *command) ([]painter.Stop, error) {
+	const expected = "gradient stop <color>@<offset>"
+	first := c.pos
+	n := len(c.args) - first
+	if n < 2 {
+		return nil, c.errorAt(len(c.args), "invalid params count", "at least 2 gradient stops")
+	}
+
+	stops := make([]painter.Stop, n)
+	for i := range stops {
+		param, _ := c.next(expected)
+		colorName, offsetText, hasOffset := strings.Cut(param, "@")
+
+		col, err := parseColor(colorName)
+		if err != nil {
+			return nil, c.errorAt(c.pos-1, "invalid color", expected)
+		}
+
+		offset := float32(i) / float32(n-1)
+		if hasOffset {
+			v, err := evalExpr(offsetText, c.vars)
+			if err != nil || v < 0 || v > 1 {
+				return nil, c.errorAt(c.pos-1, "invalid params", "gradient stop offset in [0, 1]")
+			}
+			offset = float32(v)
+		}
+
+		stops[i] = painter.Stop{Offset: offset, Color: col}
+	}
+
+	painter.SortStops(stops)
+	return stops, nil
+}
+
+func parseColorPair(c *command) (a, b color.Color, err error) {
+	if a, err = c.color(); err != nil {
+		return nil, nil, err
+	}
+	if b, err = c.color(); err != nil {
+		return nil, nil, err
+	}
+	return a, b, nil
+}
@@ -0,0 +1,150 @@
+package lang
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// maxCallDepth обмежує вкладеність викликів процедур, у тому числі рекурсивних.
+const maxCallDepth = 32
+
+// Proc процедура скрипта, визначена як "proc <назва>(<параметр>, ...) { ... }".
+// Виклик "<назва>(<вираз>, ...)" виконує тіло процедури з параметрами, що дорівнюють значенням виразів.
+// Змінні, задані у тілі через let, локальні для виклику; глобальні змінні скрипта доступні лише для читання.
+type Proc struct {
+	Name   string   `json:"name"`
+	Params []string `json:"params"`
+	Source string   `json:"source"` // Текст визначення процедури
+
+	body []statement
+	file string // Скрипт бібліотеки, в якому визначено процедуру
+}
+
+// Procs бібліотека процедур, спільна для усіх скриптів, які розбирає Parser. Нульове значення готове до використання.
+type Procs struct {
+	mu sync.RWMutex
+	m  map[string]*Proc
+}
+
+// Define додає процедуру до бібліотеки, замінюючи процедуру з такою ж назвою.
+func (l *Procs) Define(p *Proc) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.m == nil {
+		l.m = map[string]*Proc{}
+	}
+	l.m[p.Name] = p
+}
+
+// Get повертає процедуру за назвою або nil, якщо її немає.
+func (l *Procs) Get(name string) *Proc {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.m[name]
+}
+
+// Delete видаляє процедуру. Повертає false, якщо процедури не було.
+func (l *Procs) Delete(name string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, ok := l.m[name]
+	delete(l.m, name)
+	return ok
+}
+
+// List повертає усі процедури, впорядковані за назвою.
+func (l *Procs) List() []*Proc {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	res := make([]*Proc, 0, len(l.m))
+	for _, p := range l.m {
+		res = append(res, p)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	return res
+}
+
+// parseProc розбирає заголовок "proc <назва>(<параметр>, ...)" блоку з тілом body.
+// Текст визначення береться з рядків скрипта text, починаючи з заголовку та закінчуючи рядком end.
+func parseProc(c *command, body []statement, text []string, end int) (*Proc, error) {
+	const expected = "<name>(<param>, ...)"
+	if !c.more() {
+		return nil, c.fail("invalid params count", expected)
+	}
+
+	var parts []string
+	for c.more() {
+		word, _ := c.next("")
+		parts = append(parts, word)
+	}
+	header := strings.Join(parts, " ")
+
+	name, params, ok := splitCall(header)
+	if !ok || !isVariable(name) {
+		err := c.errorAt(0, "invalid procedure header", expected)
+		err.Got = fmt.Sprintf("%q", header)
+		return nil, err
+	}
+
+	p := &Proc{Name: name, Params: []string{}, body: body}
+	seen := map[string]bool{}
+	for _, param := range params {
+		param = strings.TrimSpace(param)
+		if !isVariable(param) || seen[param] {
+			err := c.errorAt(0, "invalid procedure parameter", "unique parameter names")
+			err.Got = fmt.Sprintf("%q", param)
+			return nil, err
+		}
+		seen[param] = true
+		p.Params = append(p.Params, param)
+	}
+
+	if start := c.name.line; start >= 1 && end <= len(text) && start <= end {
+		p.Source = strings.Join(text[start-1:end], "\n")
+	}
+	return p, nil
+}
+
+// isCall перевіряє, чи команда є викликом процедури, тобто містить '(' у першому слові або одразу після нього.
+func isCall(tokens []token) bool {
+	return strings.Contains(tokens[0].text, "(") || len(tokens) > 1 && strings.HasPrefix(tokens[1].text, "(")
+}
+
+// splitCall розбирає рядок виду "<назва>(<аргумент>, ...)" на назву та аргументи, розділені комами поза дужками.
+func splitCall(s string) (string, []string, bool) {
+	open := strings.IndexByte(s, '(')
+	if open < 0 || !strings.HasSuffix(s, ")") {
+		return "", nil, false
+	}
+	name, inner := strings.TrimSpace(s[:open]), s[open+1:len(s)-1]
+	if strings.TrimSpace(inner) == "" {
+		return name, nil, true
+	}
+
+	var (
+		args  []string
+		depth int
+		start int
+	)
+	for i := 0; i < len(inner); i++ {
+		switch inner[i] {
+		case '(':
+			depth++
+		case ')':
+			if depth--; depth < 0 {
+				return "", nil, false
+			}
+		case ',':
+			if depth == 0 {
+				args = append(args, inner[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if depth != 0 {
+		return "", nil, false
+	}
+	return name, append(args, inner[start:]), true
+}
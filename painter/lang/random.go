@@ -0,0 +1,120 @@
+package lang
+
+import (
+	"github.com/roman-mazur/architecture-lab-3/painter"
+)
+
+// maxRandomFigures обмежує кількість фігур, які створює одна команда "random figures".
+const maxRandomFigures = 10000
+
+// randomOptions необов'язкові параметри команд генерації.
+type randomOptions struct {
+	area   painter.Area
+	seed   int64
+	colors bool
+	group  string
+}
+
+// parseRandomOptions розбирає решту аргументів команди як опції "in <x1> <y1> <x2> <y2>", "seed=<n>", "colors"
+// та "group <назва>" у довільному порядку. Опції, не дозволені командою через allowed, вважаються помилкою.
+func parseRandomOptions(c *command, allowed ...string) (randomOptions, error) {
+	opts := randomOptions{area: painter.FullArea}
+
+	isAllowed := func(name string) bool {
+		for _, a := range allowed {
+			if a == name {
+				return true
+			}
+		}
+		return false
+	}
+
+	for c.more() {
+		seed, ok, err := c.seed()
+		switch {
+		case err != nil:
+			return opts, err
+		case ok:
+			opts.seed = seed
+		case isAllowed("in") && c.keyword("in"):
+			in := c.pos - 1
+			values, err := c.coords(4)
+			if err != nil {
+				return opts, err
+			}
+			if values[0] > values[2] || values[1] > values[3] {
+				return opts, c.errorAt(in, "invalid coordinates", "area corners ordered as x1 <= x2, y1 <= y2")
+			}
+			opts.area = painter.Area{X1: values[0], Y1: values[1], X2: values[2], Y2: values[3]}
+		case isAllowed("colors") && c.keyword("colors"):
+			opts.colors = true
+		case isAllowed("group") && c.keyword("group"):
+			if opts.group, err = c.groupName(); err != nil {
+				return opts, err
+			}
+		default:
+			return opts, c.fail("invalid params", "option")
+		}
+	}
+
+	return opts, nil
+}
+
+// parseRandom розбирає команди "random figures <n> [in ...] [colors] [seed=<n>]", "random rect [in ...] [seed=<n>]"
+// та "random colors [group <назва>] [seed=<n>]".
+func parseRandom(c *command) (painter.Operation, error) {
+	kind, err := c.oneOf("figures", "rect", "colors")
+	if err != nil {
+		return nil, err
+	}
+
+	switch kind {
+	case "figures":
+		count, err := c.integerFrom(1)
+		if err != nil {
+			return nil, err
+		}
+		if count > maxRandomFigures {
+			return nil, c.exprError("invalid params", "at most 10000 figures")
+		}
+		opts, err := parseRandomOptions(c, "in", "colors")
+		if err != nil {
+			return nil, err
+		}
+		return painter.RandomFigures{Count: count, Area: opts.area, Colors: opts.colors, Seed: opts.seed}, nil
+	case "rect":
+		opts, err := parseRandomOptions(c, "in")
+		if err != nil {
+			return nil, err
+		}
+		return painter.RandomRect{Area: opts.area, Seed: opts.seed}, nil
+	default:
+		opts, err := parseRandomOptions(c, "group")
+		if err != nil {
+			return nil, err
+		}
+		return painter.RandomColors{Group: opts.group, Seed: opts.seed}, nil
+	}
+}
+
+// parseScatter розбирає команду "scatter [group <назва>] [in <x1> <y1> <x2> <y2>] [seed=<n>]".
+func parseScatter(c *command) (painter.Operation, error) {
+	opts, err := parseRandomOptions(c, "group", "in")
+	if err != nil {
+		return nil, err
+	}
+	return painter.Scatter{Group: opts.group, Area: opts.area, Seed: opts.seed}, nil
+}
+
+// parseJitter розбирає команду "jitter <відстань> [group <назва>] [seed=<n>]".
+func parseJitter(c *command) (painter.Operation, error) {
+	amount, err := c.coord()
+	if err != nil {
+		return nil, err
+	}
+	opts, err := parseRandomOptions(c, "group")
+	if err != nil {
+		return nil, err
+	}
+	return painter.Jitter{Group: opts.group, Amount: amount, Seed: opts.seed}, nil
+}
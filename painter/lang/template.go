@@ -0,0 +1,175 @@
+package lang
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/roman-mazur/architecture-lab-3/painter"
+)
+
+// maxTemplateOutput обмежує розмір скрипта, отриманого з шаблону.
+const maxTemplateOutput = 1 << 20
+
+// maxTemplateIterations обмежує сумарну кількість ітерацій усіх range шаблону, адже порожнє тіло циклу
+// нічого не виводить і не обмежується maxTemplateOutput.
+const maxTemplateIterations = 100000
+
+// templateFuncs функції, доступні у шаблонах скриптів, на додачу до вбудованих функцій text/template.
+// Жодна з них не має доступу до файлів, мережі чи стану сервера.
+var templateFuncs = template.FuncMap{
+	"add": func(a, b float64) float64 { return a + b },
+	"sub": func(a, b float64) float64 { return a - b },
+	"mul": func(a, b float64) float64 { return a * b },
+	"div": func(a, b float64) (float64, error) {
+		if b == 0 {
+			return 0, errors.New("division by zero")
+		}
+		return a / b, nil
+	},
+	"num": func(v float64) string { return strconv.FormatFloat(v, 'g', -1, 32) },
+}
+
+// RenderTemplate підставляє параметри params у шаблон скрипта src, записаний у синтаксисі text/template.
+// Рядкові параметри мають бути одним словом скрипта, тому не можуть додати до скрипта нові команди чи коментарі.
+// Відсутні у params ключі вважаються помилкою. range дозволено лише над списками та об'єктами з обмеженням
+// maxTemplateIterations на загальну кількість ітерацій, а define та template заборонені.
+func RenderTemplate(src string, params map[string]any) (string, error) {
+	if err := checkParam("", params); err != nil {
+		return "", err
+	}
+
+	iterations := 0
+	tmpl, err := template.New("script").Option("missingkey=error").Funcs(templateFuncs).Funcs(template.FuncMap{
+		rangeGuard: func(v any) (any, error) {
+			if v == nil {
+				return v, nil
+			}
+			switch rv := reflect.ValueOf(v); rv.Kind() {
+			case reflect.Slice, reflect.Array, reflect.Map:
+				if iterations += rv.Len(); iterations > maxTemplateIterations {
+					return nil, errors.New("too many range iterations")
+				}
+				return v, nil
+			}
+			return nil, fmt.Errorf("range over %T is not allowed", v)
+		},
+	}).Parse(src)
+	if err != nil {
+		return "", err
+	}
+	if len(tmpl.Templates()) > 1 {
+		return "", errors.New("template definitions are not allowed")
+	}
+	if err := guardRanges(tmpl.Tree.Root); err != nil {
+		return "", err
+	}
+
+	var out strings.Builder
+	if err := tmpl.Execute(&limitedWriter{w: &out, n: maxTemplateOutput}, params); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// rangeGuard назва функції, через яку guardRanges пропускає значення кожного range.
+const rangeGuard = "rangeGuard"
+
+// guardRanges додає rangeGuard в кінець конвеєра кожного range шаблону та забороняє виклики інших шаблонів.
+func guardRanges(node parse.Node) error {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return nil
+		}
+		for _, child := range n.Nodes {
+			if err := guardRanges(child); err != nil {
+				return err
+			}
+		}
+	case *parse.IfNode:
+		return guardBranch(&n.BranchNode)
+	case *parse.WithNode:
+		return guardBranch(&n.BranchNode)
+	case *parse.RangeNode:
+		n.Pipe.Cmds = append(n.Pipe.Cmds, &parse.CommandNode{
+			NodeType: parse.NodeCommand,
+			Pos:      n.Pipe.Pos,
+			Args:     []parse.Node{parse.NewIdentifier(rangeGuard).SetPos(n.Pipe.Pos)},
+		})
+		return guardBranch(&n.BranchNode)
+	case *parse.TemplateNode:
+		return errors.New("template calls are not allowed")
+	}
+	return nil
+}
+
+func guardBranch(n *parse.BranchNode) error {
+	if err := guardRanges(n.List); err != nil {
+		return err
+	}
+	return guardRanges(n.ElseList)
+}
+
+// checkParam перевіряє значення параметра шаблону з назвою path. Рядки та ключі об'єктів мають бути одним словом.
+func checkParam(path string, v any) error {
+	switch v := v.(type) {
+	case string:
+		return checkWord(path, v)
+	case []any:
+		for i, item := range v {
+			if err := checkParam(fmt.Sprintf("%s[%d]", path, i), item); err != nil {
+				return err
+			}
+		}
+	case map[string]any:
+		for k, item := range v {
+			keyPath := k
+			if path != "" {
+				keyPath = path + "." + k
+			}
+			if err := checkWord(keyPath, k); err != nil {
+				return err
+			}
+			if err := checkParam(keyPath, item); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func checkWord(path, s string) error {
+	if s == "" || strings.HasPrefix(s, "#") && !isHexColorWord(s) || strings.HasPrefix(s, "//") || strings.ContainsAny(s, " \t\r\n;{}") {
+		return fmt.Errorf("%w: parameter %s must be a single word", ErrInvalidName, path)
+	}
+	return nil
+}
+
+// limitedWriter повертає помилку, якщо записано більше n байтів.
+type limitedWriter struct {
+	w io.Writer
+	n int
+}
+
+func (lw *limitedWriter) Write(p []byte) (int, error) {
+	if len(p) > lw.n {
+		return 0, errors.New("rendered script is too large")
+	}
+	lw.n -= len(p)
+	return lw.w.Write(p)
+}
+
+// ParseTemplate підставляє параметри у шаблон скрипта та розбирає отриманий скрипт.
+func (p *Parser) ParseTemplate(src string, params map[string]any) ([]painter.Operation, error) {
+	script, err := RenderTemplate(src, params)
+	if err != nil {
+		return nil, err
+	}
+	return p.Parse(strings.NewReader(script))
+}
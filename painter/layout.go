@@ -0,0 +1,77 @@
+package painter
+
+import "math"
+
+// LayoutGrid операція розміщує фігури по центрах клітинок сітки з Rows рядків та Cols стовпців.
+// Сітка займає текстуру з відступом Margin від кожного краю. Фігури, яким не вистачило клітинок, не переміщуються.
+type LayoutGrid struct {
+	Group  string // Назва групи, порожня назва означає усі фігури
+	Rows   int
+	Cols   int
+	Margin float32
+}
+
+func (op LayoutGrid) Update(state *TextureState) {
+	figs, moved := state.figuresByID(state.targetFigures(op.Group))
+	if op.Rows*op.Cols < len(moved) {
+		figs, moved = figs[:op.Rows*op.Cols], moved[:op.Rows*op.Cols]
+	}
+
+	cellW := (1 - 2*op.Margin) / float32(op.Cols)
+	cellH := (1 - 2*op.Margin) / float32(op.Rows)
+	for i := range moved {
+		row, col := i/op.Cols, i%op.Cols
+		moved[i].X = op.Margin + (float32(col)+0.5)*cellW
+		moved[i].Y = op.Margin + (float32(row)+0.5)*cellH
+	}
+	state.placeFigures("layout", figs, moved)
+}
+
+// LayoutLine операція рівномірно розміщує фігури на відрізку від (X1, Y1) до (X2, Y2).
+type LayoutLine struct {
+	Group string
+	X1    float32
+	Y1    float32
+	X2    float32
+	Y2    float32
+}
+
+func (op LayoutLine) Update(state *TextureState) {
+	figs, moved := state.figuresByID(state.targetFigures(op.Group))
+	for i := range moved {
+		t := float32(0.5)
+		if len(moved) > 1 {
+			t = float32(i) / float32(len(moved)-1)
+		}
+		moved[i].X = op.X1 + (op.X2-op.X1)*t
+		moved[i].Y = op.Y1 + (op.Y2-op.Y1)*t
+	}
+	state.placeFigures("layout", figs, moved)
+}
+
+// LayoutCircle операція рівномірно розміщує фігури на колі з центром (X, Y) та радіусом R,
+// починаючи з верхньої точки кола за годинниковою стрілкою.
+type LayoutCircle struct {
+	Group string
+	X     float32
+	Y     float32
+	R     float32
+}
+
+func (op LayoutCircle) Update(state *TextureState) {
+	figs, moved := state.figuresByID(state.targetFigures(op.Group))
+	for i := range moved {
+		angle := 2*math.Pi*float64(i)/float64(len(moved)) - math.Pi/2
+		moved[i].X = op.X + op.R*float32(math.Cos(angle))
+		moved[i].Y = op.Y + op.R*float32(math.Sin(angle))
+	}
+	state.placeFigures("layout", figs, moved)
+}
+
+// targetFigures повертає ідентифікатори фігур групи, або усіх фігур, якщо назву групи не вказано.
+func (s *TextureState) targetFigures(group string) []int {
+	if group == "" {
+		return append([]int(nil), s.figureIDs...)
+	}
+	return s.groupFigures(group)
+}
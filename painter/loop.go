@@ -3,8 +3,12 @@ package painter
 import (
 	"image"
 	"image/color"
+	"log"
+	"sync"
+	"time"
 
 	"golang.org/x/exp/shiny/screen"
+	"golang.org/x/image/draw"
 )
 
 // Receiver отримує текстуру, яка була підготовлена в результаті виконання команд у циелі подій.
@@ -15,11 +19,15 @@ type Receiver interface {
 // Loop реалізує цикл подій для формування текстури отриманої через виконання операцій отриманих з внутрішньої черги.
 type Loop struct {
 	Receiver Receiver
+	Bounds   BoundsPolicy // Політика розміщення фігур, що виходять за межі текстури
 
-	next screen.Texture // Текстура, яка зараз формується
-	prev screen.Texture // Текстура, яка була відправлена останнього разу у Receiver
+	next   screen.Texture // Текстура, яка зараз формується
+	prev   screen.Texture // Текстура, яка була відправлена останнього разу у Receiver
+	canvas *Canvas        // Програмна текстура, на якій малюється кадр перед завантаженням у next
+	buf    screen.Buffer  // Буфер для завантаження canvas у next, спільний для всіх кадрів
 
 	mq       MessageQueue
+	mu       sync.Mutex // Захищає state від одночасного читання поза циклом подій
 	state    TextureState
 	doneFunc func()
 }
@@ -29,36 +37,88 @@ var size = image.Pt(600, 600)
 // Start запускає цикл подій. Цей метод потрібно запустити до того, як викликати на ньому будь-які інші методи.
 func (l *Loop) Start(s screen.Screen) {
 	l.next, _ = s.NewTexture(size)
+	l.canvas = NewCanvas(size)
 	l.mq = MessageQueue{queue: make(chan Operation)}
-	l.state = TextureState{backgroundColor: &Fill{Color: color.White}}
+	l.state = TextureState{backgroundColor: &Fill{Color: color.White}, bounds: l.Bounds}
 
 	go func() {
-		for {
-			e := l.mq.Pull()
-
-			switch e.(type) {
-			case Figure, BgRect, Move, Fill, Reset:
-				e.Update(&l.state)
-			case Update:
-				l.state.backgroundColor.Do(l.next)
+		ticker := time.NewTicker(SimulationStep)
+		defer ticker.Stop()
 
-				if l.state.backgroundRect != nil {
-					l.state.backgroundRect.Do(l.next)
-				}
+		for {
+			// Поки симуляція вимкнена, канал тактів залишається nil і не обирається.
+			var tick <-chan time.Time
+			if l.state.simulation != nil {
+				tick = ticker.C
+			}
 
-				for _, fig := range l.state.figureCenters {
-					fig.Do(l.next)
+			select {
+			case e := <-l.mq.queue:
+				l.handle(s, e)
+				if l.doneFunc != nil {
+					l.doneFunc()
 				}
-				l.prev = l.next
-				l.Receiver.Update(l.next)
-				l.next, _ = s.NewTexture(size)
+			case <-tick:
+				l.mu.Lock()
+				l.state.step(float32(SimulationStep.Seconds()))
+				l.mu.Unlock()
+				l.render(s)
 			}
+		}
+	}()
+}
 
-			if l.doneFunc != nil {
-				l.doneFunc()
+func (l *Loop) handle(s screen.Screen, e Operation) {
+	switch e := e.(type) {
+	case Update:
+		l.render(s)
+	case checkedList:
+		violations := l.Check(e.ops)
+		accepted := len(violations) == 0 || l.Bounds != BoundsReject
+		if accepted {
+			for _, op := range Optimize(e.ops, l.Bounds) {
+				l.handle(s, op)
 			}
 		}
-	}()
+		e.res <- checkResult{violations: violations, accepted: accepted}
+	default:
+		l.mu.Lock()
+		e.Update(&l.state)
+		for _, v := range l.state.takeViolations() {
+			log.Printf("Bounds violation: %s", v)
+		}
+		l.mu.Unlock()
+	}
+}
+
+// render малює поточний стан у текстуру та відправляє її у Receiver.
+func (l *Loop) render(s screen.Screen) {
+	l.mu.Lock()
+	l.state.draw(l.canvas)
+	l.mu.Unlock()
+
+	if err := l.upload(s); err != nil {
+		log.Printf("Failed to upload frame: %s", err)
+	}
+
+	l.prev = l.next
+	l.Receiver.Update(l.next)
+	l.next, _ = s.NewTexture(size)
+}
+
+// upload копіює пікселі canvas у текстуру next через буфер, який створюється при першому виклику.
+func (l *Loop) upload(s screen.Screen) error {
+	if l.buf == nil {
+		buf, err := s.NewBuffer(size)
+		if err != nil {
+			return err
+		}
+		l.buf = buf
+	}
+
+	draw.Draw(l.buf.RGBA(), l.buf.Bounds(), l.canvas.RGBA(), image.Point{}, draw.Src)
+	l.next.Upload(image.Point{}, l.buf, l.buf.Bounds())
+	return nil
 }
 
 // Post додає нову операцію у внутрішню чергу.
@@ -69,6 +129,89 @@ func (l *Loop) Post(ol OperationList) {
 	}
 }
 
+// PostChecked перевіряє операції на порушення меж і, якщо політика меж дозволяє, застосовує їх, попередньо
+// вилучивши операції без видимого результату. На відміну від Check, перевірка виконується у циклі подій, тобто
+// після всіх операцій, що були додані у чергу раніше, і жодна інша операція не виконується між перевіркою та
+// застосуванням. Повертає порушення меж та чи були операції застосовані.
+func (l *Loop) PostChecked(ol OperationList) ([]BoundsError, bool) {
+	res := make(chan checkResult, 1)
+	l.mq.Push(checkedList{ops: ol, res: res})
+	r := <-res
+	return r.violations, r.accepted
+}
+
+// checkedList операція циклу подій, що застосовує список операцій лише після успішної перевірки меж.
+type checkedList struct {
+	ops OperationList
+	res chan<- checkResult
+}
+
+func (op checkedList) Update(_ *TextureState) {}
+
+type checkResult struct {
+	violations []BoundsError
+	accepted   bool
+}
+
+// Scene повертає знімок поточного стану сцени.
+func (l *Loop) Scene() Scene {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.state.Scene()
+}
+
+// HitTest повертає об'єкти поточної сцени, що містять точку (x, y), починаючи з верхнього.
+func (l *Loop) HitTest(x, y float32) []Hit {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.state.HitTest(x, y)
+}
+
+// FiguresIn повертає фігури поточної сцени, що перетинаються з прямокутником (x1, y1)-(x2, y2).
+func (l *Loop) FiguresIn(x1, y1, x2, y2 float32) []SceneFigure {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.state.FiguresIn(x1, y1, x2, y2)
+}
+
+// Check застосовує операції до копії поточного стану та повертає порушення меж, які вони спричинять.
+// Сам стан циклу при цьому не змінюється. Операції, що ще чекають у черзі, не враховуються, дивись PostChecked.
+func (l *Loop) Check(ol OperationList) []BoundsError {
+	return l.DryRun(ol).Violations
+}
+
+// Preview стан сцени після пробного застосування операцій.
+type Preview struct {
+	Violations []BoundsError // Порушення меж, які спричинили операції
+
+	state TextureState
+}
+
+// DryRun застосовує операції до копії поточного стану, не змінюючи стан циклу та не формуючи кадрів.
+func (l *Loop) DryRun(ol OperationList) *Preview {
+	l.mu.Lock()
+	state := l.state.clone()
+	l.mu.Unlock()
+
+	state.violations = nil
+	for _, op := range ol {
+		op.Update(&state)
+	}
+	return &Preview{Violations: state.takeViolations(), state: state}
+}
+
+// Scene повертає знімок сцени після застосування операцій.
+func (p *Preview) Scene() Scene {
+	return p.state.Scene()
+}
+
+// Image малює кадр сцени після застосування операцій, разом з ефектами та фільтрами.
+func (p *Preview) Image() *image.RGBA {
+	canvas := NewCanvas(size)
+	p.state.draw(canvas)
+	return canvas.RGBA()
+}
+
 // MessageQueue черга повідомлень
 type MessageQueue struct {
 	queue chan Operation
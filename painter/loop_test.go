@@ -429,3 +429,334 @@ func TestUpdate(t *testing.T) {
 		t.Error("Update works incorrectly")
 	}
 }
+
+func TestGroupMove(t *testing.T) {
+	ops := OperationList{
+		Figure{X: 0.2, Y: 0.2},
+		Figure{X: 0.4, Y: 0.2},
+		Figure{X: 0.9, Y: 0.9},
+		Group{Name: "pair", Members: []string{"1", "2"}},
+		GroupMove{Name: "pair", X: 0.5, Y: 0.5},
+	}
+
+	c := makeChecker(len(ops))
+	loop := Loop{Receiver: &MockReceiver{}, doneFunc: c.done}
+	loop.Start(MockScreen{})
+	loop.Post(ops)
+	c.check()
+
+	first := Figure{X: 0.4, Y: 0.5}
+	second := Figure{X: 0.6, Y: 0.5}
+	third := Figure{X: 0.9, Y: 0.9}
+
+	figs := loop.state.figureCenters
+	if !closeFigure(*figs[0], first) || !closeFigure(*figs[1], second) || *figs[2] != third {
+		t.Error("Group move works incorrectly")
+	}
+}
+
+func TestNestedGroups(t *testing.T) {
+	ops := OperationList{
+		Figure{X: 0.2, Y: 0.2},
+		Figure{X: 0.4, Y: 0.4},
+		Figure{X: 0.6, Y: 0.6},
+		Group{Name: "inner", Members: []string{"1", "2"}},
+		Group{Name: "outer", Members: []string{"inner", "3"}},
+		GroupScale{Name: "outer", Factor: 0.5},
+		GroupColor{Name: "inner", Color: color.Black},
+		GroupDelete{Name: "inner"},
+	}
+
+	c := makeChecker(len(ops))
+	loop := Loop{Receiver: &MockReceiver{}, doneFunc: c.done}
+	loop.Start(MockScreen{})
+	loop.Post(ops)
+	c.check()
+
+	scene := loop.Scene()
+	if len(scene.Figures) != 1 || scene.Figures[0].ID != 3 || scene.Figures[0].Scale != 0.5 || scene.Figures[0].Color != "" {
+		t.Errorf("Nested groups work incorrectly: %+v", scene.Figures)
+	}
+	if !closeFigure(*loop.state.figureCenters[0], Figure{X: 0.5, Y: 0.5, Scale: 0.5}) {
+		t.Error("Group scale works incorrectly")
+	}
+	if len(scene.Groups) != 1 || scene.Groups[0].Name != "outer" {
+		t.Errorf("Incorrect groups: %+v", scene.Groups)
+	}
+}
+
+func closeFigure(a, b Figure) bool {
+	const eps = 1e-5
+	return a.X-b.X < eps && b.X-a.X < eps && a.Y-b.Y < eps && b.Y-a.Y < eps && a.Scale == b.Scale
+}
+
+func TestDeleteAndReplace(t *testing.T) {
+	ops := OperationList{
+		BgRect{X1: 0.1, Y1: 0.1, X2: 0.3, Y2: 0.3},
+		Figure{X: 0.5, Y: 0.5},
+		Figure{X: 0.9, Y: 0.9},
+		Figure{X: 0.1, Y: 0.9},
+		Figure{X: 0.7, Y: 0.1},
+		DeleteFigure{ID: 2},
+		DeleteIndex{Index: 0},
+		DeleteAt{X: 0.1, Y: 0.9},
+		ReplaceFigure{ID: 4, Figure: Figure{X: 0.2, Y: 0.8, Scale: 2}},
+		DeleteAt{X: 0.9, Y: 0.15},
+	}
+
+	c := makeChecker(len(ops))
+	loop := Loop{Receiver: &MockReceiver{}, doneFunc: c.done}
+	loop.Start(MockScreen{})
+	loop.Post(ops)
+	c.check()
+
+	if len(loop.state.figureCenters) != 1 || loop.state.figureIDs[0] != 4 || *loop.state.figureCenters[0] != (Figure{X: 0.2, Y: 0.8, Scale: 2}) {
+		t.Errorf("Incorrect figures: %+v", loop.Scene().Figures)
+	}
+	if loop.state.backgroundRect == nil {
+		t.Error("Rect should not be deleted")
+	}
+}
+
+func TestDeleteRectAt(t *testing.T) {
+	ops := OperationList{
+		BgRect{X1: 0.1, Y1: 0.1, X2: 0.3, Y2: 0.3},
+		ReplaceRect{Rect: BgRect{X1: 0.6, Y1: 0.6, X2: 0.9, Y2: 0.9}},
+		DeleteAt{X: 0.7, Y: 0.7},
+		ReplaceRect{Rect: BgRect{X1: 0.1, Y1: 0.1, X2: 0.3, Y2: 0.3}},
+	}
+
+	c := makeChecker(len(ops))
+	loop := Loop{Receiver: &MockReceiver{}, doneFunc: c.done}
+	loop.Start(MockScreen{})
+	loop.Post(ops)
+	c.check()
+
+	if loop.state.backgroundRect != nil {
+		t.Error("Rect should be deleted")
+	}
+}
+
+func TestDeleteRectStyle(t *testing.T) {
+	var state TextureState
+	ops := OperationList{
+		PushClip{Clip: ClipRect{X1: 0.2, Y1: 0.2, X2: 0.8, Y2: 0.8}},
+		BgRect{X1: 0.1, Y1: 0.1, X2: 0.3, Y2: 0.3},
+		RectFill{Pattern: &Checkerboard{Size: 0.1, A: color.Black, B: color.White}},
+		SetStroke{Rect: true, Stroke: &Stroke{Color: color.Black, Width: 2}},
+		SetHollow{Rect: true, Hollow: true},
+		SetShadow{Rect: true, Shadow: &Shadow{DX: 2, DY: 2, Color: color.Black}},
+		SetGlow{Rect: true, Glow: &Glow{Radius: 3, Color: color.White}},
+		DeleteRect{},
+		PopClip{},
+		BgRect{X1: 0.5, Y1: 0.5, X2: 0.7, Y2: 0.7},
+	}
+	for _, op := range ops {
+		op.Update(&state)
+	}
+
+	if state.rectPattern != nil || state.rectStroke != nil || state.rectHollow || state.rectShadow != nil ||
+		state.rectGlow != nil || len(state.rectClips) != 0 {
+		t.Errorf("New rect inherited the style of the deleted one: %+v", state.Scene().Rect)
+	}
+}
+
+func TestHitTest(t *testing.T) {
+	var state TextureState
+	ops := OperationList{
+		BgRect{X1: 0, Y1: 0, X2: 0.5, Y2: 0.5},
+		Figure{X: 0.5, Y: 0.5},
+		Figure{X: 0.5, Y: 0.6},
+	}
+	for _, op := range ops {
+		op.Update(&state)
+	}
+
+	// Точка на перекладині обох фігур T.
+	hits := state.HitTest(0.2, 0.45)
+	if len(hits) != 3 || hits[0].Figure.ID != 2 || hits[1].Figure.ID != 1 || hits[2].Kind != HitRect {
+		t.Errorf("Incorrect hits: %+v", hits)
+	}
+
+	// Точка поруч з ніжкою T, але поза контуром фігури.
+	if hits := state.HitTest(0.8, 0.9); len(hits) != 0 {
+		t.Errorf("Unexpected hits: %+v", hits)
+	}
+
+	figs := state.FiguresIn(0.55, 0.95, 0.6, 1)
+	if len(figs) != 1 || figs[0].ID != 2 {
+		t.Errorf("Incorrect intersection: %+v", figs)
+	}
+}
+
+func TestBoundsClamp(t *testing.T) {
+	ops := OperationList{
+		Figure{X: 0.5, Y: 0.5},
+		Move{X: 0.01, Y: 0.99},
+	}
+
+	c := makeChecker(len(ops))
+	loop := Loop{Receiver: &MockReceiver{}, Bounds: BoundsClamp, doneFunc: c.done}
+	loop.Start(MockScreen{})
+	loop.Post(ops)
+	c.check()
+
+	fig := loop.state.figureCenters[0]
+	if !fig.visible() || fig.bounds().Min.X != 0 || fig.bounds().Max.Y != size.Y {
+		t.Errorf("Figure is not clamped: %+v", fig.bounds())
+	}
+}
+
+func TestBoundsReject(t *testing.T) {
+	ops := OperationList{
+		Figure{X: 0.5, Y: 0.5},
+		Figure{X: 0.1, Y: 0.1},
+		Figure{X: 0.45, Y: 0.4},
+		Group{Name: "g", Members: []string{"1", "2"}},
+		GroupMove{Name: "g", X: 0.9, Y: 0.9},
+		Move{X: 0.5, Y: 0.45},
+	}
+
+	c := makeChecker(len(ops))
+	loop := Loop{Receiver: &MockReceiver{}, Bounds: BoundsReject, doneFunc: c.done}
+	loop.Start(MockScreen{})
+
+	violations := loop.Check(ops)
+	if len(violations) != 3 || violations[0].Op != "figure" || violations[1].Op != "move" || violations[1].ID != 1 {
+		t.Errorf("Incorrect violations: %+v", violations)
+	}
+
+	loop.Post(ops)
+	c.check()
+
+	first := Figure{X: 0.5, Y: 0.45}
+	second := Figure{X: 0.5, Y: 0.45}
+	if len(loop.state.figureCenters) != 2 || *loop.state.figureCenters[0] != first || *loop.state.figureCenters[1] != second {
+		t.Errorf("Incorrect figures: %+v", loop.Scene().Figures)
+	}
+}
+
+func TestPostChecked(t *testing.T) {
+	loop := Loop{Receiver: &MockReceiver{}, Bounds: BoundsReject}
+	loop.Start(MockScreen{})
+
+	// Перевірка враховує операції, що ще чекають у черзі.
+	loop.Post(OperationList{Figure{X: 0.5, Y: 0.5}})
+	violations, accepted := loop.PostChecked(OperationList{Move{X: 0.1, Y: 0.5}})
+	if accepted || len(violations) != 1 || violations[0].Op != "move" {
+		t.Errorf("Incorrect check result: %v, %+v", accepted, violations)
+	}
+
+	violations, accepted = loop.PostChecked(OperationList{Move{X: 0.45, Y: 0.4}, Figure{X: 0.5, Y: 0.45}})
+	if !accepted || len(violations) != 0 {
+		t.Errorf("Incorrect check result: %v, %+v", accepted, violations)
+	}
+	if figs := loop.Scene().Figures; len(figs) != 2 || figs[0].X != 0.45 {
+		t.Errorf("Incorrect figures: %+v", figs)
+	}
+}
+
+func TestLayouts(t *testing.T) {
+	var state TextureState
+	ops := OperationList{
+		Figure{X: 0.1, Y: 0.1},
+		Figure{X: 0.1, Y: 0.1},
+		Figure{X: 0.1, Y: 0.1},
+		Figure{X: 0.1, Y: 0.1},
+		Figure{X: 0.1, Y: 0.1},
+		LayoutGrid{Rows: 2, Cols: 2, Margin: 0.1},
+	}
+	for _, op := range ops {
+		op.Update(&state)
+	}
+
+	grid := []Figure{{X: 0.3, Y: 0.3}, {X: 0.7, Y: 0.3}, {X: 0.3, Y: 0.7}, {X: 0.7, Y: 0.7}, {X: 0.1, Y: 0.1}}
+	for i, fig := range state.figureCenters {
+		if !closeFigure(*fig, grid[i]) {
+			t.Errorf("Incorrect grid position %d: %+v", i, *fig)
+		}
+	}
+
+	Group{Name: "g", Members: []string{"1", "2", "3"}}.Update(&state)
+	LayoutLine{Group: "g", X1: 0.2, Y1: 0.5, X2: 0.8, Y2: 0.5}.Update(&state)
+	line := []Figure{{X: 0.2, Y: 0.5}, {X: 0.5, Y: 0.5}, {X: 0.8, Y: 0.5}}
+	for i, want := range line {
+		if !closeFigure(*state.figureCenters[i], want) {
+			t.Errorf("Incorrect line position %d: %+v", i, *state.figureCenters[i])
+		}
+	}
+
+	LayoutCircle{Group: "g", X: 0.5, Y: 0.5, R: 0.25}.Update(&state)
+	if !closeFigure(*state.figureCenters[0], Figure{X: 0.5, Y: 0.25}) {
+		t.Errorf("Incorrect circle position: %+v", *state.figureCenters[0])
+	}
+}
+
+func TestSimulationStep(t *testing.T) {
+	var state TextureState
+	ops := OperationList{
+		Figure{X: 0.5, Y: 0.4},
+		Figure{X: 0.5, Y: 0.4},
+		Velocity{ID: 1, VX: 0.3},
+		Acceleration{ID: 2, AY: 0.6},
+		Simulate{Enabled: true},
+	}
+	for _, op := range ops {
+		op.Update(&state)
+	}
+
+	state.step(0.25)
+	if !closeFigure(*state.figureCenters[0], Figure{X: 0.575, Y: 0.4}) || !closeFigure(*state.figureCenters[1], Figure{X: 0.5, Y: 0.4375}) {
+		t.Errorf("Incorrect positions: %+v, %+v", *state.figureCenters[0], *state.figureCenters[1])
+	}
+
+	// Перша фігура досягає правого краю та відбивається.
+	state.step(0.25)
+	if !state.figureCenters[0].visible() || state.motion[1].VX != -0.3 {
+		t.Errorf("Figure did not bounce: %+v, %+v", *state.figureCenters[0], *state.motion[1])
+	}
+}
+
+func TestRandomVelocitySeed(t *testing.T) {
+	var first, second TextureState
+	for _, state := range []*TextureState{&first, &second} {
+		Figure{X: 0.5, Y: 0.5}.Update(state)
+		Figure{X: 0.5, Y: 0.5}.Update(state)
+		RandomVelocity{Max: 0.2, Seed: 42}.Update(state)
+	}
+
+	for id, m := range first.motion {
+		if *m != *second.motion[id] || m.VX < -0.2 || m.VX > 0.2 {
+			t.Errorf("Velocities are not reproducible: %+v, %+v", *m, *second.motion[id])
+		}
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	loop := Loop{Receiver: &MockReceiver{}, Bounds: BoundsReject}
+	loop.Start(MockScreen{})
+
+	preview := loop.DryRun(OperationList{
+		BgRect{X1: 0, Y1: 0, X2: 0.1, Y2: 0.1},
+		Figure{X: 0.5, Y: 0.5},
+		Figure{X: 0, Y: 0},
+	})
+
+	if len(preview.Violations) != 1 || preview.Violations[0].Op != "figure" {
+		t.Errorf("Incorrect violations: %+v", preview.Violations)
+	}
+	if scene := preview.Scene(); len(scene.Figures) != 1 || scene.Rect == nil {
+		t.Errorf("Incorrect preview scene: %+v", scene)
+	}
+	if scene := loop.Scene(); len(scene.Figures) != 0 || scene.Rect != nil {
+		t.Errorf("Dry run changed the loop state: %+v", scene)
+	}
+
+	img := preview.Image()
+	if c := img.RGBAAt(5, 5); c != (color.RGBA{A: 0xff}) {
+		t.Errorf("Rect is not drawn: %v", c)
+	}
+	if c := img.RGBAAt(590, 590); c != (color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}) {
+		t.Errorf("Background is not drawn: %v", c)
+	}
+}
@@ -41,6 +41,7 @@ func (op Fill) Do(t screen.Texture) {
 
 func (op Fill) Update(state *TextureState) {
 	state.backgroundColor = &op
+	state.backgroundPattern = nil
 }
 
 type Reset struct{}
@@ -50,8 +51,16 @@ var ResetOp = Reset{}
 
 func (op Reset) Update(state *TextureState) {
 	state.backgroundColor = &Fill{Color: color.Black}
-	state.backgroundRect = nil
+	state.backgroundPattern = nil
+	state.removeRect()
 	state.figureCenters = nil
+	state.figureIDs = nil
+	state.figureClips = nil
+	state.nextID = 0
+	state.groups = nil
+	state.motion = nil
+	state.simulation = nil
+	state.clips = nil
 }
 
 // BgRect операція додає чорний прямокутник на екран в певних координатах
@@ -63,40 +72,80 @@ type BgRect struct {
 }
 
 func (op BgRect) Do(t screen.Texture) {
-	t.Fill(
-		image.Rect(
-			int(op.X1*float32(t.Size().X)),
-			int(op.Y1*float32(t.Size().Y)),
-			int(op.X2*float32(t.Size().X)),
-			int(op.Y2*float32(t.Size().Y)),
-		),
-		color.Black,
-		screen.Src,
+	t.Fill(op.rect(t.Size()), color.Black, screen.Src)
+}
+
+// rect повертає прямокутник у пікселях текстури розміру sz.
+func (op BgRect) rect(sz image.Point) image.Rectangle {
+	return image.Rect(
+		int(op.X1*float32(sz.X)),
+		int(op.Y1*float32(sz.Y)),
+		int(op.X2*float32(sz.X)),
+		int(op.Y2*float32(sz.Y)),
 	)
 }
 
 func (op BgRect) Update(state *TextureState) {
 	state.backgroundRect = &op
+	state.rectClips = state.activeClips()
 }
 
 // Figure операція додає фігуру варіанту на вказані координати
 type Figure struct {
 	X float32
 	Y float32
+
+	Scale   float32     // Масштаб фігури, нуль означає звичайний розмір
+	Color   color.Color // Колір фігури, nil означає ui.TColor
+	Pattern Pattern     // Візерунок фігури, має пріоритет над Color
+	Stroke  *Stroke     // Контур фігури, nil означає фігуру без контуру
+	Hollow  bool        // Фігура не заливається
+	Shadow  *Shadow     // Тінь, малюється лише на програмній текстурі
+	Glow    *Glow       // Світіння, малюється лише на програмній текстурі
 }
 
 func (op Figure) Do(t screen.Texture) {
-	ui.DrawT(
-		t,
-		image.Pt(
-			int(op.X*float32(t.Size().X)),
-			int(op.Y*float32(t.Size().Y)),
-		),
+	p := image.Pt(
+		int(op.X*float32(t.Size().X)),
+		int(op.Y*float32(t.Size().Y)),
 	)
+
+	rects := ui.TRects(p, float64(op.scale()))
+	if canvas, ok := t.(*Canvas); ok && (op.Shadow != nil || op.Glow != nil) {
+		drawEffects(canvas.RGBA(), rects[:], op.Shadow, op.Glow)
+	}
+
+	switch {
+	case op.Hollow:
+	case op.Pattern != nil:
+		fillPattern(t, rects[:], rects[0].Union(rects[1]), op.Pattern)
+	default:
+		ui.DrawScaledT(t, p, float64(op.scale()), op.color())
+	}
+
+	if op.Stroke != nil {
+		strokePolygon(t, ui.TOutline(p, float64(op.scale())), op.Stroke)
+	}
 }
 
 func (op Figure) Update(state *TextureState) {
-	state.figureCenters = append(state.figureCenters, &op)
+	if state.reserveFigures("figure", 1) && state.placeFigures("figure", []*Figure{&op}, []Figure{op}) {
+		state.addFigure(&op)
+	}
+}
+
+func (op Figure) scale() float32 {
+	if op.Scale == 0 {
+		return 1
+	}
+	return op.Scale
+}
+
+func (op Figure) color() color.Color {
+	if op.Color == nil {
+		return ui.TColor
+	}
+	return op.Color
 }
 
 // Move операція переміщує усі на відповідну кількість пікселів
@@ -106,8 +155,11 @@ type Move struct {
 }
 
 func (op Move) Update(state *TextureState) {
-	for _, fig := range state.figureCenters {
-		fig.X = op.X
-		fig.Y = op.Y
+	moved := make([]Figure, len(state.figureCenters))
+	for i, fig := range state.figureCenters {
+		moved[i] = *fig
+		moved[i].X = op.X
+		moved[i].Y = op.Y
 	}
+	state.placeFigures("move", state.figureCenters, moved)
 }
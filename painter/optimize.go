@@ -0,0 +1,90 @@
+package painter
+
+// Optimize повертає коротший список операцій, що дає той самий кінцевий стан сцени та той самий кадр на
+// останньому Update. Вилучаються проміжні Update, операції перед Reset (окрім фільтрів, які Reset не скидає),
+// заливки та прямокутники, перезаписані пізнішими операціями, та фільтри, замінені SetFilters.
+// Послідовні Move та ReplaceFigure однієї фігури зливаються в останню з них, якщо політика bounds не BoundsReject:
+// відхилення пізнішої операції залишило б результат попередньої.
+func Optimize(ol OperationList, bounds BoundsPolicy) OperationList {
+	last := -1
+	for i, op := range ol {
+		if _, ok := op.(Update); ok {
+			last = i
+		}
+	}
+	if last < 0 {
+		return optimizeSegment(ol, bounds)
+	}
+
+	// Операції після останнього Update не потрапляють у кадр, тому не можуть перезаписувати операції до нього.
+	res := optimizeSegment(ol[:last], bounds)
+	res = append(res, ol[last])
+	return append(res, optimizeSegment(ol[last+1:], bounds)...)
+}
+
+// optimizeSegment вилучає операції без впливу на стан після усього списку ol, переглядаючи його з кінця.
+func optimizeSegment(ol OperationList, bounds BoundsPolicy) OperationList {
+	var ops OperationList
+	for _, op := range ol {
+		if _, ok := op.(Update); !ok {
+			ops = append(ops, op)
+		}
+	}
+
+	// Прапорці означають, що відповідну частину стану буде перезаписано пізнішою операцією.
+	var reset, bgColor, bgPattern, rect, rectPattern, filters bool
+	keep := make([]bool, len(ops))
+	for i := len(ops) - 1; i >= 0; i-- {
+		var dead bool
+		switch ops[i].(type) {
+		case AddFilter:
+			dead = filters
+		case SetFilters:
+			dead, filters = filters, true
+		default:
+			if reset {
+				dead = true
+				break
+			}
+			switch op := ops[i].(type) {
+			case Reset:
+				reset = true
+			case Fill:
+				dead, bgColor, bgPattern = bgColor, true, true
+			case PatternFill:
+				dead, bgPattern = bgPattern, true
+			case RectFill:
+				dead, rectPattern = rectPattern, true
+			case BgRect, RandomRect, DeleteRect:
+				dead, rect = rect, true
+			case ReplaceRect:
+				// Результат залежить від наявності прямокутника, тому попередні операції з ним потрібні.
+				dead = rect
+			case DeleteAt:
+				rect = false
+			case Move:
+				_, ok := nextOp(ops, i).(Move)
+				dead = ok && bounds != BoundsReject
+			case ReplaceFigure:
+				next, ok := nextOp(ops, i).(ReplaceFigure)
+				dead = ok && bounds != BoundsReject && next.ID == op.ID
+			}
+		}
+		keep[i] = !dead
+	}
+
+	var res OperationList
+	for i, op := range ops {
+		if keep[i] {
+			res = append(res, op)
+		}
+	}
+	return res
+}
+
+func nextOp(ops OperationList, i int) Operation {
+	if i+1 < len(ops) {
+		return ops[i+1]
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package painter
+
+import (
+	"bytes"
+	"image/color"
+	"reflect"
+	"testing"
+)
+
+// renderOps застосовує операції до початкового стану циклу та повертає сцену і кадр на останньому Update.
+func renderOps(ol OperationList, bounds BoundsPolicy) (Scene, []byte) {
+	state := TextureState{backgroundColor: &Fill{Color: color.White}, bounds: bounds}
+	var frame []byte
+	for _, op := range ol {
+		op.Update(&state)
+		if _, ok := op.(Update); ok {
+			canvas := NewCanvas(size)
+			state.draw(canvas)
+			frame = canvas.RGBA().Pix
+		}
+	}
+	return state.Scene(), frame
+}
+
+func TestOptimize(t *testing.T) {
+	red := color.RGBA{R: 0xff, A: 0xff}
+	stripes := &Stripes{Width: 0.1, A: color.White, B: color.Black}
+
+	for _, tc := range []struct {
+		name   string
+		bounds BoundsPolicy
+		ops    OperationList
+		want   OperationList
+	}{
+		{
+			name: "fills",
+			ops:  OperationList{Fill{Color: color.White}, PatternFill{Pattern: stripes}, Fill{Color: red}, UpdateOp},
+			want: OperationList{Fill{Color: red}, UpdateOp},
+		},
+		{
+			name: "pattern over fill",
+			ops:  OperationList{Fill{Color: red}, PatternFill{Pattern: stripes}, RectFill{Pattern: stripes}, RectFill{Pattern: stripes}, UpdateOp},
+			want: OperationList{Fill{Color: red}, PatternFill{Pattern: stripes}, RectFill{Pattern: stripes}, UpdateOp},
+		},
+		{
+			name: "rects",
+			ops: OperationList{
+				BgRect{X2: 0.5, Y2: 0.5}, BgRect{X2: 0.2, Y2: 0.2},
+				DeleteAt{X: 0.1, Y: 0.1}, BgRect{X1: 0.5, Y1: 0.5, X2: 1, Y2: 1},
+				ReplaceRect{Rect: BgRect{X2: 0.3, Y2: 0.3}}, BgRect{X2: 0.4, Y2: 0.4}, UpdateOp,
+			},
+			want: OperationList{BgRect{X2: 0.2, Y2: 0.2}, DeleteAt{X: 0.1, Y: 0.1}, BgRect{X2: 0.4, Y2: 0.4}, UpdateOp},
+		},
+		{
+			name: "moves and updates",
+			ops: OperationList{
+				Figure{X: 0.5, Y: 0.5}, Move{X: 0.1, Y: 0.1}, UpdateOp, Move{X: 0.2, Y: 0.2}, Move{X: 0.3, Y: 0.3},
+				ReplaceFigure{ID: 1, Figure: Figure{X: 0.4, Y: 0.4}}, ReplaceFigure{ID: 1, Figure: Figure{X: 0.6, Y: 0.6}}, UpdateOp, Move{X: 0.9, Y: 0.9},
+			},
+			want: OperationList{Figure{X: 0.5, Y: 0.5}, Move{X: 0.3, Y: 0.3}, ReplaceFigure{ID: 1, Figure: Figure{X: 0.6, Y: 0.6}}, UpdateOp, Move{X: 0.9, Y: 0.9}},
+		},
+		{
+			name:   "moves with reject",
+			bounds: BoundsReject,
+			ops:    OperationList{Figure{X: 0.5, Y: 0.5}, Move{X: 0.3, Y: 0.3}, Move{X: 0, Y: 0}, UpdateOp},
+			want:   OperationList{Figure{X: 0.5, Y: 0.5}, Move{X: 0.3, Y: 0.3}, Move{X: 0, Y: 0}, UpdateOp},
+		},
+		{
+			name: "reset",
+			ops: OperationList{
+				Figure{X: 0.5, Y: 0.5}, AddFilter{Filter: Invert{}}, BgRect{X2: 0.5, Y2: 0.5}, Reset{},
+				AddFilter{Filter: Grayscale{}}, Figure{X: 0.2, Y: 0.2}, UpdateOp,
+			},
+			want: OperationList{AddFilter{Filter: Invert{}}, Reset{}, AddFilter{Filter: Grayscale{}}, Figure{X: 0.2, Y: 0.2}, UpdateOp},
+		},
+		{
+			name: "filters",
+			ops:  OperationList{AddFilter{Filter: Invert{}}, Reset{}, SetFilters{Filters: []Filter{Grayscale{}}}, UpdateOp},
+			want: OperationList{Reset{}, SetFilters{Filters: []Filter{Grayscale{}}}, UpdateOp},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			res := Optimize(tc.ops, tc.bounds)
+			if !reflect.DeepEqual(res, tc.want) {
+				t.Errorf("Incorrect optimization:\n got %+v\nwant %+v", res, tc.want)
+			}
+
+			scene, frame := renderOps(tc.ops, tc.bounds)
+			optScene, optFrame := renderOps(res, tc.bounds)
+			if !reflect.DeepEqual(scene, optScene) {
+				t.Errorf("Scene differs:\n got %+v\nwant %+v", optScene, scene)
+			}
+			if !bytes.Equal(frame, optFrame) {
+				t.Error("Last frame differs")
+			}
+		})
+	}
+}
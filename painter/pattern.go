@@ -0,0 +1,261 @@
+package painter
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"sort"
+	"strings"
+
+	"golang.org/x/exp/shiny/screen"
+)
+
+// Pattern задає колір кожної точки області заливки. String повертає візерунок у синтаксисі скриптів.
+type Pattern interface {
+	// At повертає колір точки (x, y) області bounds.
+	At(x, y int, bounds image.Rectangle) color.NRGBA
+	String() string
+}
+
+// Stop опорна точка градієнта: колір на відстані Offset від початку градієнта, від 0 до 1.
+type Stop struct {
+	Offset float32
+	Color  color.Color
+}
+
+// LinearGradient лінійний градієнт у напрямку Angle градусів: 0 — зліва направо, 90 — згори донизу.
+type LinearGradient struct {
+	Angle float32
+	Stops []Stop
+}
+
+func (g *LinearGradient) At(x, y int, bounds image.Rectangle) color.NRGBA {
+	return g.shader(bounds)(x, y)
+}
+
+func (g *LinearGradient) shader(bounds image.Rectangle) func(x, y int) color.NRGBA {
+	rad := float64(g.Angle) * math.Pi / 180
+	dx, dy := math.Cos(rad), math.Sin(rad)
+
+	// Проєкції кутів області на напрямок градієнта визначають його початок та кінець.
+	proj := func(x, y int) float64 {
+		return float64(x)*dx + float64(y)*dy
+	}
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, p := range corners(bounds) {
+		v := proj(p.X, p.Y)
+		lo, hi = math.Min(lo, v), math.Max(hi, v)
+	}
+	if hi == lo {
+		c := interpolate(g.Stops, 0)
+		return func(_, _ int) color.NRGBA { return c }
+	}
+	return func(x, y int) color.NRGBA {
+		return interpolate(g.Stops, float32((proj(x, y)-lo)/(hi-lo)))
+	}
+}
+
+func (g *LinearGradient) String() string {
+	return fmt.Sprintf("linear %g %s", g.Angle, formatStops(g.Stops))
+}
+
+// RadialGradient радіальний градієнт з центром у точці (CX, CY) відносно області заливки.
+// Градієнт закінчується у найвіддаленішому від центру куті області.
+type RadialGradient struct {
+	CX    float32
+	CY    float32
+	Stops []Stop
+}
+
+func (g *RadialGradient) At(x, y int, bounds image.Rectangle) color.NRGBA {
+	return g.shader(bounds)(x, y)
+}
+
+func (g *RadialGradient) shader(bounds image.Rectangle) func(x, y int) color.NRGBA {
+	cx := float64(bounds.Min.X) + float64(g.CX)*float64(bounds.Dx())
+	cy := float64(bounds.Min.Y) + float64(g.CY)*float64(bounds.Dy())
+
+	var radius float64
+	for _, p := range corners(bounds) {
+		radius = math.Max(radius, math.Hypot(float64(p.X)-cx, float64(p.Y)-cy))
+	}
+	if radius == 0 {
+		c := interpolate(g.Stops, 0)
+		return func(_, _ int) color.NRGBA { return c }
+	}
+	return func(x, y int) color.NRGBA {
+		return interpolate(g.Stops, float32(math.Hypot(float64(x)-cx, float64(y)-cy)/radius))
+	}
+}
+
+func (g *RadialGradient) String() string {
+	return fmt.Sprintf("radial %g %g %s", g.CX, g.CY, formatStops(g.Stops))
+}
+
+// Checkerboard шахова дошка з клітинками розміром Size у частках ширини текстури.
+type Checkerboard struct {
+	Size float32
+	A    color.Color
+	B    color.Color
+}
+
+func (p *Checkerboard) At(x, y int, bounds image.Rectangle) color.NRGBA {
+	cell := patternUnit(p.Size)
+	if ((x-bounds.Min.X)/cell+(y-bounds.Min.Y)/cell)%2 == 0 {
+		return toNRGBA(p.A)
+	}
+	return toNRGBA(p.B)
+}
+
+func (p *Checkerboard) String() string {
+	return fmt.Sprintf("checker %g %s %s", p.Size, HexColor(p.A), HexColor(p.B))
+}
+
+// Stripes смуги шириною Width у частках ширини текстури, нахилені на Angle градусів.
+type Stripes struct {
+	Width float32
+	Angle float32
+	A     color.Color
+	B     color.Color
+}
+
+func (p *Stripes) At(x, y int, bounds image.Rectangle) color.NRGBA {
+	rad := float64(p.Angle) * math.Pi / 180
+	u := float64(x-bounds.Min.X)*math.Cos(rad) + float64(y-bounds.Min.Y)*math.Sin(rad)
+	if int(math.Floor(u/float64(patternUnit(p.Width))))%2 == 0 {
+		return toNRGBA(p.A)
+	}
+	return toNRGBA(p.B)
+}
+
+func (p *Stripes) String() string {
+	return fmt.Sprintf("stripes %g %g %s %s", p.Width, p.Angle, HexColor(p.A), HexColor(p.B))
+}
+
+// PatternFill операція заливає тло текстури візерунком.
+type PatternFill struct {
+	Pattern Pattern
+}
+
+func (op PatternFill) Update(state *TextureState) {
+	state.backgroundPattern = op.Pattern
+}
+
+// RectFill операція задає візерунок, яким заливається прямокутник сцени замість чорного кольору.
+type RectFill struct {
+	Pattern Pattern
+}
+
+func (op RectFill) Update(state *TextureState) {
+	state.rectPattern = op.Pattern
+}
+
+// FigureFill операція заливає візерунком фігуру з ідентифікатором ID, фігури групи Group або усі фігури.
+type FigureFill struct {
+	ID      int
+	Group   string
+	Pattern Pattern
+}
+
+func (op FigureFill) Update(state *TextureState) {
+	for _, id := range state.selectFigures(op.ID, op.Group) {
+		state.figureCenters[state.figureIndex(id)].Pattern = op.Pattern
+	}
+}
+
+// shader реалізують візерунки, колір яких залежить від розрахунків для всієї області. Функція, що повертає
+// shader, виконує ці розрахунки один раз для області bounds, а не для кожної точки.
+type shader interface {
+	shader(bounds image.Rectangle) func(x, y int) color.NRGBA
+}
+
+// fillPattern заливає прямокутники rects візерунком, розрахованим для області bounds.
+// Текстури без доступу до пікселів заливаються кольором центру області.
+func fillPattern(t screen.Texture, rects []image.Rectangle, bounds image.Rectangle, p Pattern) {
+	canvas, ok := t.(*Canvas)
+	if !ok {
+		c := bounds.Min.Add(bounds.Max).Div(2)
+		for _, r := range rects {
+			t.Fill(r, p.At(c.X, c.Y, bounds), screen.Src)
+		}
+		return
+	}
+
+	at := func(x, y int) color.NRGBA {
+		return p.At(x, y, bounds)
+	}
+	if s, ok := p.(shader); ok {
+		at = s.shader(bounds)
+	}
+
+	img := canvas.RGBA()
+	for _, r := range rects {
+		r = r.Intersect(img.Rect)
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				img.SetRGBA(x, y, premultiply(at(x, y)))
+			}
+		}
+	}
+}
+
+// corners повертає кути прямокутника r.
+func corners(r image.Rectangle) [4]image.Point {
+	return [4]image.Point{r.Min, r.Max, {X: r.Min.X, Y: r.Max.Y}, {X: r.Max.X, Y: r.Min.Y}}
+}
+
+// interpolate повертає колір градієнта у точці t.
+func interpolate(stops []Stop, t float32) color.NRGBA {
+	if len(stops) == 0 {
+		return color.NRGBA{}
+	}
+	if t <= stops[0].Offset {
+		return toNRGBA(stops[0].Color)
+	}
+	for i := 1; i < len(stops); i++ {
+		if t <= stops[i].Offset {
+			a, b := toNRGBA(stops[i-1].Color), toNRGBA(stops[i].Color)
+			k := (t - stops[i-1].Offset) / (stops[i].Offset - stops[i-1].Offset)
+			lerp := func(a, b uint8) uint8 {
+				return uint8(float32(a) + (float32(b)-float32(a))*k + 0.5)
+			}
+			return color.NRGBA{R: lerp(a.R, b.R), G: lerp(a.G, b.G), B: lerp(a.B, b.B), A: lerp(a.A, b.A)}
+		}
+	}
+	return toNRGBA(stops[len(stops)-1].Color)
+}
+
+// SortStops впорядковує опорні точки градієнта за зростанням відстані.
+func SortStops(stops []Stop) {
+	sort.SliceStable(stops, func(i, j int) bool {
+		return stops[i].Offset < stops[j].Offset
+	})
+}
+
+func formatStops(stops []Stop) string {
+	parts := make([]string, len(stops))
+	for i, s := range stops {
+		parts[i] = fmt.Sprintf("%s@%g", HexColor(s.Color), s.Offset)
+	}
+	return strings.Join(parts, " ")
+}
+
+// patternUnit переводить розмір у частках ширини текстури в пікселі, не менше одного.
+func patternUnit(v float32) int {
+	if px := int(v * float32(size.X)); px > 0 {
+		return px
+	}
+	return 1
+}
+
+func toNRGBA(c color.Color) color.NRGBA {
+	return color.NRGBAModel.Convert(c).(color.NRGBA)
+}
+
+func premultiply(c color.NRGBA) color.RGBA {
+	mul := func(v uint8) uint8 {
+		return uint8(uint16(v) * uint16(c.A) / 0xff)
+	}
+	return color.RGBA{R: mul(c.R), G: mul(c.G), B: mul(c.B), A: c.A}
+}
@@ -0,0 +1,51 @@
+package painter
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestLinearGradient(t *testing.T) {
+	g := &LinearGradient{Stops: []Stop{{Offset: 0, Color: color.Black}, {Offset: 1, Color: color.White}}}
+	bounds := image.Rect(0, 0, 100, 10)
+
+	if c := g.At(0, 5, bounds); c != (color.NRGBA{A: 0xff}) {
+		t.Errorf("Incorrect start color: %v", c)
+	}
+	if c := g.At(50, 5, bounds); c.R != 0x80 {
+		t.Errorf("Incorrect middle color: %v", c)
+	}
+	if c := g.At(100, 5, bounds); c != (color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}) {
+		t.Errorf("Incorrect end color: %v", c)
+	}
+}
+
+func TestPatternRendering(t *testing.T) {
+	var state TextureState
+	ops := OperationList{
+		Fill{Color: color.White},
+		PatternFill{Pattern: &Checkerboard{Size: 0.5, A: color.Black, B: color.White}},
+		Figure{X: 0.5, Y: 0.5},
+		FigureFill{ID: 1, Pattern: &RadialGradient{CX: 0.5, CY: 0.5, Stops: []Stop{{Color: color.White}, {Offset: 1, Color: color.White}}}},
+	}
+	for _, op := range ops {
+		op.Update(&state)
+	}
+
+	canvas := NewCanvas(size)
+	state.draw(canvas)
+	img := canvas.RGBA()
+
+	if img.RGBAAt(10, 10) != (color.RGBA{A: 0xff}) || img.RGBAAt(590, 10) != (color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}) {
+		t.Error("Incorrect checkerboard background")
+	}
+	if img.RGBAAt(300, 200) != (color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}) {
+		t.Error("Incorrect figure fill")
+	}
+
+	Fill{Color: color.Black}.Update(&state)
+	if state.backgroundPattern != nil {
+		t.Error("Flat fill should replace the pattern")
+	}
+}
@@ -0,0 +1,149 @@
+package painter
+
+import (
+	"math/rand"
+	"time"
+)
+
+// SimulationStep фіксований крок симуляції. За кожен крок стан оновлюється та формується новий кадр.
+const SimulationStep = time.Second / 30
+
+// Motion описує рух фігури. Швидкість задається у частках текстури за секунду, прискорення — за секунду у квадраті.
+type Motion struct {
+	VX float32
+	VY float32
+	AX float32
+	AY float32
+}
+
+// Velocity операція задає швидкість фігури з ідентифікатором ID, фігур групи Group або усіх фігур.
+type Velocity struct {
+	ID    int
+	Group string
+	VX    float32
+	VY    float32
+}
+
+func (op Velocity) Update(state *TextureState) {
+	for _, id := range state.selectFigures(op.ID, op.Group) {
+		m := state.motionOf(id)
+		m.VX, m.VY = op.VX, op.VY
+	}
+}
+
+// Acceleration операція задає прискорення фігури з ідентифікатором ID, фігур групи Group або усіх фігур.
+type Acceleration struct {
+	ID    int
+	Group string
+	AX    float32
+	AY    float32
+}
+
+func (op Acceleration) Update(state *TextureState) {
+	for _, id := range state.selectFigures(op.ID, op.Group) {
+		m := state.motionOf(id)
+		m.AX, m.AY = op.AX, op.AY
+	}
+}
+
+// RandomVelocity операція задає усім фігурам випадкові швидкості з діапазону [-Max, Max].
+// Однакове значення Seed дає однакові швидкості.
+type RandomVelocity struct {
+	Max  float32
+	Seed int64
+}
+
+func (op RandomVelocity) Update(state *TextureState) {
+	rnd := rand.New(rand.NewSource(op.Seed))
+	for _, id := range state.figureIDs {
+		m := state.motionOf(id)
+		m.VX = (rnd.Float32()*2 - 1) * op.Max
+		m.VY = (rnd.Float32()*2 - 1) * op.Max
+	}
+}
+
+// Simulate операція вмикає або вимикає симуляцію руху фігур.
+// Під час симуляції цикл подій сам формує кадри з кроком SimulationStep.
+type Simulate struct {
+	Enabled    bool
+	Collisions bool // Фігури відштовхуються одна від одної
+}
+
+func (op Simulate) Update(state *TextureState) {
+	if !op.Enabled {
+		state.simulation = nil
+		return
+	}
+	state.simulation = &op
+}
+
+// selectFigures повертає ідентифікатор фігури id, фігури групи або усі фігури, якщо нічого не вказано.
+func (s *TextureState) selectFigures(id int, group string) []int {
+	if id > 0 {
+		if s.figureIndex(id) < 0 {
+			return nil
+		}
+		return []int{id}
+	}
+	return s.targetFigures(group)
+}
+
+func (s *TextureState) motionOf(id int) *Motion {
+	if s.motion == nil {
+		s.motion = map[int]*Motion{}
+	}
+	if s.motion[id] == nil {
+		s.motion[id] = &Motion{}
+	}
+	return s.motion[id]
+}
+
+// step просуває симуляцію на dt секунд: рухає фігури, відбиває їх від країв текстури
+// та, якщо ввімкнено, одна від одної.
+func (s *TextureState) step(dt float32) {
+	for i, fig := range s.figureCenters {
+		m := s.motion[s.figureIDs[i]]
+		if m == nil {
+			continue
+		}
+
+		m.VX += m.AX * dt
+		m.VY += m.AY * dt
+		fig.X += m.VX * dt
+		fig.Y += m.VY * dt
+
+		b := fig.bounds()
+		if b.Min.X < 0 && m.VX < 0 || b.Max.X > size.X && m.VX > 0 {
+			m.VX = -m.VX
+		}
+		if b.Min.Y < 0 && m.VY < 0 || b.Max.Y > size.Y && m.VY > 0 {
+			m.VY = -m.VY
+		}
+		if !fig.visible() {
+			fig.clamp()
+		}
+	}
+
+	if s.simulation != nil && s.simulation.Collisions {
+		s.collide()
+	}
+}
+
+// collide обмінює швидкості фігур, обмежувальні прямокутники яких перетинаються і які рухаються назустріч.
+func (s *TextureState) collide() {
+	for i, a := range s.figureCenters {
+		for j := i + 1; j < len(s.figureCenters); j++ {
+			b := s.figureCenters[j]
+			if !a.bounds().Overlaps(b.bounds()) {
+				continue
+			}
+
+			ma, mb := s.motionOf(s.figureIDs[i]), s.motionOf(s.figureIDs[j])
+			// Відносна швидкість, спроєктована на лінію центрів, від'ємна для фігур, що зближуються.
+			if (mb.VX-ma.VX)*(b.X-a.X)+(mb.VY-ma.VY)*(b.Y-a.Y) < 0 {
+				ma.VX, mb.VX = mb.VX, ma.VX
+				ma.VY, mb.VY = mb.VY, ma.VY
+			}
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package painter
+
+import (
+	"image/color"
+	"math/rand"
+)
+
+// Area прямокутна область у нормалізованих координатах, в якій генеруються випадкові об'єкти.
+type Area struct {
+	X1 float32
+	Y1 float32
+	X2 float32
+	Y2 float32
+}
+
+// FullArea область, що охоплює всю текстуру.
+var FullArea = Area{X2: 1, Y2: 1}
+
+func (a Area) point(rnd *rand.Rand) (float32, float32) {
+	return a.X1 + rnd.Float32()*(a.X2-a.X1), a.Y1 + rnd.Float32()*(a.Y2-a.Y1)
+}
+
+// RandomFigures операція додає Count фігур у випадкових точках області Area.
+// Якщо встановлено Colors, фігури отримують випадкові кольори. Однакове значення Seed дає однакові фігури.
+// Операція відхиляється повністю, якщо з новими фігурами на сцені буде більше MaxFigures фігур.
+type RandomFigures struct {
+	Count  int
+	Area   Area
+	Colors bool
+	Seed   int64
+}
+
+func (op RandomFigures) Update(state *TextureState) {
+	if !state.reserveFigures("figure", op.Count) {
+		return
+	}
+	rnd := rand.New(rand.NewSource(op.Seed))
+	for i := 0; i < op.Count; i++ {
+		fig := Figure{}
+		fig.X, fig.Y = op.Area.point(rnd)
+		if op.Colors {
+			fig.Color = randomColor(rnd)
+		}
+		fig.Update(state)
+	}
+}
+
+// RandomRect операція задає прямокутник сцени з випадковими кутами в межах області Area.
+type RandomRect struct {
+	Area Area
+	Seed int64
+}
+
+func (op RandomRect) Update(state *TextureState) {
+	rnd := rand.New(rand.NewSource(op.Seed))
+	x1, y1 := op.Area.point(rnd)
+	x2, y2 := op.Area.point(rnd)
+	if x1 > x2 {
+		x1, x2 = x2, x1
+	}
+	if y1 > y2 {
+		y1, y2 = y2, y1
+	}
+	state.backgroundRect = &BgRect{X1: x1, Y1: y1, X2: x2, Y2: y2}
+	state.rectClips = state.activeClips()
+}
+
+// RandomColors операція перефарбовує фігури групи Group, або усі фігури, у випадкові кольори.
+type RandomColors struct {
+	Group string
+	Seed  int64
+}
+
+func (op RandomColors) Update(state *TextureState) {
+	rnd := rand.New(rand.NewSource(op.Seed))
+	for _, id := range state.targetFigures(op.Group) {
+		state.figureCenters[state.figureIndex(id)].Color = randomColor(rnd)
+	}
+}
+
+// Scatter операція переносить фігури групи Group, або усі фігури, у випадкові точки області Area.
+type Scatter struct {
+	Group string
+	Area  Area
+	Seed  int64
+}
+
+func (op Scatter) Update(state *TextureState) {
+	rnd := rand.New(rand.NewSource(op.Seed))
+	figs, moved := state.figuresByID(state.targetFigures(op.Group))
+	for i := range moved {
+		moved[i].X, moved[i].Y = op.Area.point(rnd)
+	}
+	state.placeFigures("scatter", figs, moved)
+}
+
+// Jitter операція зсуває кожну фігуру групи Group, або усі фігури, на випадкову відстань до Amount по кожній осі.
+type Jitter struct {
+	Group  string
+	Amount float32
+	Seed   int64
+}
+
+func (op Jitter) Update(state *TextureState) {
+	rnd := rand.New(rand.NewSource(op.Seed))
+	figs, moved := state.figuresByID(state.targetFigures(op.Group))
+	for i := range moved {
+		moved[i].X += (rnd.Float32()*2 - 1) * op.Amount
+		moved[i].Y += (rnd.Float32()*2 - 1) * op.Amount
+	}
+	state.placeFigures("jitter", figs, moved)
+}
+
+func randomColor(rnd *rand.Rand) color.Color {
+	return color.RGBA{R: uint8(rnd.Intn(0x100)), G: uint8(rnd.Intn(0x100)), B: uint8(rnd.Intn(0x100)), A: 0xff}
+}
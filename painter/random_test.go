@@ -0,0 +1,58 @@
+package painter
+
+import "testing"
+
+func TestRandomFiguresReproducible(t *testing.T) {
+	area := Area{X1: 0.4, Y1: 0.3, X2: 0.6, Y2: 0.5}
+
+	var first, second TextureState
+	for _, state := range []*TextureState{&first, &second} {
+		ops := OperationList{
+			RandomFigures{Count: 20, Area: area, Colors: true, Seed: 42},
+			RandomRect{Area: area, Seed: 7},
+			Jitter{Amount: 0.01, Seed: 3},
+		}
+		for _, op := range ops {
+			op.Update(state)
+		}
+	}
+
+	if len(first.figureCenters) != 20 {
+		t.Fatalf("Expected 20 figures, got %d", len(first.figureCenters))
+	}
+	for i, fig := range first.figureCenters {
+		if *fig != *second.figureCenters[i] {
+			t.Errorf("Figure %d is not reproducible: %+v, %+v", i, *fig, *second.figureCenters[i])
+		}
+		if fig.X < area.X1-0.01 || fig.X > area.X2+0.01 || fig.Y < area.Y1-0.01 || fig.Y > area.Y2+0.01 || fig.Color == nil {
+			t.Errorf("Figure %d is outside of the area: %+v", i, *fig)
+		}
+	}
+	r := first.backgroundRect
+	if *r != *second.backgroundRect || r.X1 < area.X1 || r.Y1 < area.Y1 || r.X2 > area.X2 || r.Y2 > area.Y2 || r.X1 > r.X2 || r.Y1 > r.Y2 {
+		t.Errorf("Incorrect random rect: %+v", *first.backgroundRect)
+	}
+
+	Scatter{Area: area, Seed: 1}.Update(&first)
+	Scatter{Area: area, Seed: 2}.Update(&second)
+	if *first.figureCenters[0] == *second.figureCenters[0] {
+		t.Error("Different seeds should give different positions")
+	}
+}
+
+func TestRandomFiguresLimit(t *testing.T) {
+	var state TextureState
+	for i := 0; i < 10; i++ {
+		RandomFigures{Count: MaxFigures / 10, Area: FullArea}.Update(&state)
+	}
+	RandomFigures{Count: 10000, Area: FullArea}.Update(&state)
+	Figure{X: 0.5, Y: 0.5}.Update(&state)
+
+	if len(state.figureCenters) != MaxFigures {
+		t.Errorf("Expected %d figures, got %d", MaxFigures, len(state.figureCenters))
+	}
+	violations := state.takeViolations()
+	if len(violations) != 2 || !violations[0].Limit || violations[1].Op != "figure" {
+		t.Errorf("Incorrect violations: %+v", violations)
+	}
+}
@@ -0,0 +1,145 @@
+package painter
+
+import (
+	"fmt"
+	"image/color"
+	"sort"
+)
+
+// Scene знімок стану сцени, придатний для серіалізації у JSON.
+type Scene struct {
+	Background     string        `json:"background,omitempty"`
+	BackgroundFill string        `json:"backgroundFill,omitempty"` // Візерунок тла у синтаксисі скриптів
+	Rect           *SceneRect    `json:"rect,omitempty"`
+	Figures        []SceneFigure `json:"figures"`
+	Groups         []SceneGroup  `json:"groups,omitempty"`
+	Clips          []string      `json:"clips,omitempty"`   // Поточний стек відсікання у синтаксисі скриптів, від нижньої області
+	Filters        []string      `json:"filters,omitempty"` // Фільтри кадру у синтаксисі скриптів
+}
+
+// SceneRect прямокутник сцени.
+type SceneRect struct {
+	X1 float32 `json:"x1"`
+	Y1 float32 `json:"y1"`
+	X2 float32 `json:"x2"`
+	Y2 float32 `json:"y2"`
+
+	Fill   string `json:"fill,omitempty"`
+	Stroke string `json:"stroke,omitempty"` // Контур у синтаксисі скриптів
+	Hollow bool   `json:"hollow,omitempty"`
+	Shadow string `json:"shadow,omitempty"` // Тінь у синтаксисі скриптів
+	Glow   string `json:"glow,omitempty"`   // Світіння у синтаксисі скриптів
+
+	Clips []string `json:"clips,omitempty"` // Стек відсікання прямокутника у синтаксисі скриптів
+}
+
+// SceneFigure фігура сцени разом з її ідентифікатором.
+type SceneFigure struct {
+	ID     int     `json:"id"`
+	X      float32 `json:"x"`
+	Y      float32 `json:"y"`
+	Scale  float32 `json:"scale"`
+	Color  string  `json:"color,omitempty"`
+	Fill   string  `json:"fill,omitempty"`
+	Stroke string  `json:"stroke,omitempty"`
+	Hollow bool    `json:"hollow,omitempty"`
+	Shadow string  `json:"shadow,omitempty"`
+	Glow   string  `json:"glow,omitempty"`
+
+	Clips []string `json:"clips,omitempty"` // Стек відсікання фігури у синтаксисі скриптів
+}
+
+// SceneGroup група фігур сцени.
+type SceneGroup struct {
+	Name    string   `json:"name"`
+	Members []string `json:"members"`
+}
+
+// Scene повертає знімок стану сцени.
+func (s *TextureState) Scene() Scene {
+	scene := Scene{Figures: []SceneFigure{}}
+
+	if s.backgroundColor != nil {
+		scene.Background = HexColor(s.backgroundColor.Color)
+	}
+	if s.backgroundPattern != nil {
+		scene.BackgroundFill = s.backgroundPattern.String()
+	}
+	if r := s.backgroundRect; r != nil {
+		scene.Rect = &SceneRect{X1: r.X1, Y1: r.Y1, X2: r.X2, Y2: r.Y2}
+		if s.rectPattern != nil {
+			scene.Rect.Fill = s.rectPattern.String()
+		}
+		if s.rectStroke != nil {
+			scene.Rect.Stroke = s.rectStroke.String()
+		}
+		scene.Rect.Hollow = s.rectHollow
+		if s.rectShadow != nil {
+			scene.Rect.Shadow = s.rectShadow.String()
+		}
+		if s.rectGlow != nil {
+			scene.Rect.Glow = s.rectGlow.String()
+		}
+		scene.Rect.Clips = clipStrings(s.rectClips)
+	}
+
+	for i := range s.figureCenters {
+		scene.Figures = append(scene.Figures, s.sceneFigure(i))
+	}
+
+	names := make([]string, 0, len(s.groups))
+	for name := range s.groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		scene.Groups = append(scene.Groups, SceneGroup{Name: name, Members: s.groups[name]})
+	}
+
+	scene.Clips = clipStrings(s.clips)
+	for _, f := range s.filters {
+		scene.Filters = append(scene.Filters, f.String())
+	}
+
+	return scene
+}
+
+func (s *TextureState) sceneFigure(i int) SceneFigure {
+	fig := s.figureCenters[i]
+	sf := SceneFigure{ID: s.figureIDs[i], X: fig.X, Y: fig.Y, Scale: fig.scale()}
+	if fig.Color != nil {
+		sf.Color = HexColor(fig.Color)
+	}
+	if fig.Pattern != nil {
+		sf.Fill = fig.Pattern.String()
+	}
+	if fig.Stroke != nil {
+		sf.Stroke = fig.Stroke.String()
+	}
+	sf.Hollow = fig.Hollow
+	if fig.Shadow != nil {
+		sf.Shadow = fig.Shadow.String()
+	}
+	if fig.Glow != nil {
+		sf.Glow = fig.Glow.String()
+	}
+	sf.Clips = clipStrings(s.figureClips[i])
+	return sf
+}
+
+// HexColor повертає колір у форматі #rrggbb, або #rrggbbaa для напівпрозорих кольорів.
+func HexColor(c color.Color) string {
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	if n.A == 0xff {
+		return fmt.Sprintf("#%02x%02x%02x", n.R, n.G, n.B)
+	}
+	return fmt.Sprintf("#%02x%02x%02x%02x", n.R, n.G, n.B, n.A)
+}
+
+func clipStrings(clips []Clip) []string {
+	var res []string
+	for _, c := range clips {
+		res = append(res, c.String())
+	}
+	return res
+}
@@ -1,7 +1,227 @@
 package painter
 
+import (
+	"image"
+	"strconv"
+
+	"golang.org/x/exp/shiny/screen"
+)
+
 type TextureState struct {
-	backgroundColor *Fill
-	backgroundRect  *BgRect
-	figureCenters   []*Figure
+	backgroundColor   *Fill
+	backgroundPattern Pattern // Візерунок тла, має пріоритет над backgroundColor
+	backgroundRect    *BgRect
+	rectPattern       Pattern // Візерунок прямокутника, nil означає чорний колір
+	rectStroke        *Stroke // Контур прямокутника
+	rectHollow        bool    // Прямокутник не заливається
+	rectShadow        *Shadow
+	rectGlow          *Glow
+	rectClips         []Clip // Стек відсікання на момент додавання прямокутника
+	figureCenters     []*Figure
+	figureIDs         []int    // Ідентифікатори фігур у тому ж порядку, що й figureCenters
+	figureClips       [][]Clip // Стек відсікання кожної фігури на момент її додавання, у тому ж порядку
+	nextID            int
+	groups            map[string][]string // Учасники груп: ідентифікатори фігур або назви вкладених груп
+
+	bounds     BoundsPolicy
+	violations []BoundsError // Порушення меж, що виникли під час застосування операцій
+
+	motion     map[int]*Motion // Рух фігур за їх ідентифікаторами
+	simulation *Simulate       // Параметри симуляції, nil якщо симуляція вимкнена
+
+	clips   []Clip   // Стек відсікання, що застосовується до нових прямокутника та фігур
+	filters []Filter // Фільтри кадру, не скидаються операцією Reset
+}
+
+// draw малює стан у текстуру.
+func (s *TextureState) draw(t screen.Texture) {
+	if s.backgroundPattern != nil {
+		fillPattern(t, []image.Rectangle{t.Bounds()}, t.Bounds(), s.backgroundPattern)
+	} else {
+		s.backgroundColor.Do(t)
+	}
+
+	canvas, isCanvas := t.(*Canvas)
+	if s.backgroundRect != nil {
+		r := s.backgroundRect.rect(t.Size())
+		s.drawClipped(t, s.rectClips, effectArea(r, s.rectStroke, s.rectShadow, s.rectGlow), func() {
+			if isCanvas && (s.rectShadow != nil || s.rectGlow != nil) {
+				drawEffects(canvas.RGBA(), []image.Rectangle{r}, s.rectShadow, s.rectGlow)
+			}
+
+			switch {
+			case s.rectHollow:
+			case s.rectPattern != nil:
+				fillPattern(t, []image.Rectangle{r}, r, s.rectPattern)
+			default:
+				s.backgroundRect.Do(t)
+			}
+
+			if s.rectStroke != nil {
+				strokePolygon(t, rectOutline(r), s.rectStroke)
+			}
+		})
+	}
+
+	for i, fig := range s.figureCenters {
+		s.drawClipped(t, s.figureClips[i], effectArea(fig.bounds(), fig.Stroke, fig.Shadow, fig.Glow), func() {
+			fig.Do(t)
+		})
+	}
+
+	if isCanvas {
+		for _, f := range s.filters {
+			f.Apply(canvas.RGBA())
+		}
+	}
+}
+
+// clone повертає копію стану, зміни якої не впливають на оригінал.
+func (s *TextureState) clone() TextureState {
+	res := *s
+	res.figureCenters = nil
+	for _, fig := range s.figureCenters {
+		copied := *fig
+		res.figureCenters = append(res.figureCenters, &copied)
+	}
+	res.figureIDs = append([]int(nil), s.figureIDs...)
+	res.figureClips = append([][]Clip(nil), s.figureClips...)
+	res.clips = append([]Clip(nil), s.clips...)
+	res.filters = append([]Filter(nil), s.filters...)
+	res.violations = append([]BoundsError(nil), s.violations...)
+
+	if s.motion != nil {
+		res.motion = make(map[int]*Motion, len(s.motion))
+		for id, m := range s.motion {
+			copied := *m
+			res.motion[id] = &copied
+		}
+	}
+
+	if s.groups != nil {
+		res.groups = make(map[string][]string, len(s.groups))
+		for name, members := range s.groups {
+			res.groups[name] = members
+		}
+	}
+	return res
+}
+
+// addFigure додає фігуру на сцену, призначаючи їй новий ідентифікатор.
+func (s *TextureState) addFigure(fig *Figure) int {
+	s.nextID++
+	s.figureCenters = append(s.figureCenters, fig)
+	s.figureIDs = append(s.figureIDs, s.nextID)
+	s.figureClips = append(s.figureClips, s.activeClips())
+	return s.nextID
+}
+
+// figureIndex повертає позицію фігури з ідентифікатором id або -1, якщо такої фігури немає.
+func (s *TextureState) figureIndex(id int) int {
+	for i, figID := range s.figureIDs {
+		if figID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// removeRect видаляє прямокутник сцени разом з його заливкою, контуром, ефектами та відсіканням, щоб наступний
+// прямокутник їх не успадкував.
+func (s *TextureState) removeRect() {
+	s.backgroundRect = nil
+	s.rectPattern = nil
+	s.rectStroke = nil
+	s.rectHollow = false
+	s.rectShadow = nil
+	s.rectGlow = nil
+	s.rectClips = nil
+}
+
+// removeFigures видаляє фігури з вказаними ідентифікаторами.
+func (s *TextureState) removeFigures(ids []int) {
+	remove := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		remove[id] = true
+		delete(s.motion, id)
+	}
+
+	var (
+		figures  []*Figure
+		figIDs   []int
+		figClips [][]Clip
+	)
+	for i, fig := range s.figureCenters {
+		if !remove[s.figureIDs[i]] {
+			figures = append(figures, fig)
+			figIDs = append(figIDs, s.figureIDs[i])
+			figClips = append(figClips, s.figureClips[i])
+		}
+	}
+	s.figureCenters = figures
+	s.figureIDs = figIDs
+	s.figureClips = figClips
+}
+
+// figuresByID повертає фігури з вказаними ідентифікаторами разом з копіями їх значень.
+func (s *TextureState) figuresByID(ids []int) ([]*Figure, []Figure) {
+	figs := make([]*Figure, len(ids))
+	values := make([]Figure, len(ids))
+	for i, id := range ids {
+		figs[i] = s.figureCenters[s.figureIndex(id)]
+		values[i] = *figs[i]
+	}
+	return figs, values
+}
+
+// groupFigures повертає ідентифікатори усіх фігур групи з урахуванням вкладених груп.
+func (s *TextureState) groupFigures(name string) []int {
+	var (
+		res     []int
+		seen    = map[int]bool{}
+		visited = map[string]bool{}
+		walk    func(name string)
+	)
+
+	walk = func(name string) {
+		if visited[name] {
+			return
+		}
+		visited[name] = true
+
+		for _, member := range s.groups[name] {
+			if id, err := strconv.Atoi(member); err == nil {
+				if !seen[id] && s.figureIndex(id) >= 0 {
+					seen[id] = true
+					res = append(res, id)
+				}
+				continue
+			}
+			walk(member)
+		}
+	}
+	walk(name)
+
+	return res
+}
+
+// groupCenter повертає центр прямокутника, що обмежує центри фігур групи.
+func (s *TextureState) groupCenter(ids []int) (float32, float32) {
+	var minX, minY, maxX, maxY float32
+	for i, id := range ids {
+		fig := s.figureCenters[s.figureIndex(id)]
+		if i == 0 || fig.X < minX {
+			minX = fig.X
+		}
+		if i == 0 || fig.Y < minY {
+			minY = fig.Y
+		}
+		if i == 0 || fig.X > maxX {
+			maxX = fig.X
+		}
+		if i == 0 || fig.Y > maxY {
+			maxY = fig.Y
+		}
+	}
+	return (minX + maxX) / 2, (minY + maxY) / 2
 }
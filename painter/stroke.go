@@ -0,0 +1,131 @@
+package painter
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"strconv"
+	"strings"
+
+	"golang.org/x/exp/shiny/screen"
+)
+
+// Stroke описує контур фігури: колір, товщину у пікселях та, за потреби, штрихування.
+// Dash чергує додатні довжини видимих та пропущених відрізків контуру у пікселях.
+type Stroke struct {
+	Color color.Color
+	Width int
+	Dash  []int
+}
+
+// String повертає контур у синтаксисі скриптів.
+func (s *Stroke) String() string {
+	res := fmt.Sprintf("%s %d", HexColor(s.Color), s.Width)
+	if len(s.Dash) > 0 {
+		parts := make([]string, len(s.Dash))
+		for i, d := range s.Dash {
+			parts[i] = strconv.Itoa(d)
+		}
+		res += " dash=" + strings.Join(parts, ",")
+	}
+	return res
+}
+
+// SetStroke операція задає контур прямокутника сцени, якщо встановлено Rect, фігури з ідентифікатором ID,
+// фігур групи Group або усіх фігур. Nil Stroke прибирає контур.
+type SetStroke struct {
+	Rect   bool
+	ID     int
+	Group  string
+	Stroke *Stroke
+}
+
+func (op SetStroke) Update(state *TextureState) {
+	if op.Rect {
+		state.rectStroke = op.Stroke
+		return
+	}
+	for _, id := range state.selectFigures(op.ID, op.Group) {
+		state.figureCenters[state.figureIndex(id)].Stroke = op.Stroke
+	}
+}
+
+// SetHollow операція вмикає або вимикає заливку прямокутника сцени чи фігур.
+// Порожня фігура з контуром малюється лише контуром.
+type SetHollow struct {
+	Rect   bool
+	ID     int
+	Group  string
+	Hollow bool
+}
+
+func (op SetHollow) Update(state *TextureState) {
+	if op.Rect {
+		state.rectHollow = op.Hollow
+		return
+	}
+	for _, id := range state.selectFigures(op.ID, op.Group) {
+		state.figureCenters[state.figureIndex(id)].Hollow = op.Hollow
+	}
+}
+
+// strokePolygon малює контур многокутника, сторони якого паралельні осям координат.
+// Лінія контуру центрується на сторонах, а штрихування продовжується через вершини.
+func strokePolygon(t screen.Texture, pts []image.Point, s *Stroke) {
+	if s.Width <= 0 || len(pts) < 2 {
+		return
+	}
+
+	half := s.Width / 2
+	dash, phase := 0, 0
+	for i := range pts {
+		a, b := pts[i], pts[(i+1)%len(pts)]
+		length := abs(b.X-a.X) + abs(b.Y-a.Y)
+		dir := image.Pt(sign(b.X-a.X), sign(b.Y-a.Y))
+
+		for pos := 0; pos < length; {
+			// Довжина поточного відрізка штрихування, що залишилась.
+			seg := length - pos
+			if len(s.Dash) > 0 && s.Dash[dash]-phase < seg {
+				seg = s.Dash[dash] - phase
+			}
+
+			if len(s.Dash) == 0 || dash%2 == 0 {
+				from, to := a.Add(dir.Mul(pos)), a.Add(dir.Mul(pos+seg))
+				r := image.Rectangle{Min: from, Max: to}.Canon()
+				r.Min = r.Min.Sub(image.Pt(half, half))
+				r.Max = r.Max.Add(image.Pt(s.Width-half, s.Width-half))
+				t.Fill(r, s.Color, screen.Src)
+			}
+
+			pos += seg
+			if len(s.Dash) > 0 {
+				if phase += seg; phase >= s.Dash[dash] {
+					dash, phase = (dash+1)%len(s.Dash), 0
+				}
+			}
+		}
+	}
+}
+
+// rectOutline повертає вершини прямокутника за годинниковою стрілкою.
+func rectOutline(r image.Rectangle) []image.Point {
+	return []image.Point{r.Min, {X: r.Max.X, Y: r.Min.Y}, r.Max, {X: r.Min.X, Y: r.Max.Y}}
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func sign(v int) int {
+	switch {
+	case v < 0:
+		return -1
+	case v > 0:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,48 @@
+package painter
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestStrokeOutline(t *testing.T) {
+	var state TextureState
+	ops := OperationList{
+		Fill{Color: color.White},
+		BgRect{X1: 0.1, Y1: 0.1, X2: 0.9, Y2: 0.9},
+		SetStroke{Rect: true, Stroke: &Stroke{Color: color.Black, Width: 4, Dash: []int{10, 10}}},
+		SetHollow{Rect: true, Hollow: true},
+	}
+	for _, op := range ops {
+		op.Update(&state)
+	}
+
+	canvas := NewCanvas(size)
+	state.draw(canvas)
+	img := canvas.RGBA()
+
+	white := color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+	black := color.RGBA{A: 0xff}
+	if img.RGBAAt(65, 60) != black || img.RGBAAt(75, 60) != white {
+		t.Error("Incorrect dashed outline")
+	}
+	if img.RGBAAt(300, 300) != white {
+		t.Error("Hollow rect should not be filled")
+	}
+}
+
+func TestStrokePolygonCorners(t *testing.T) {
+	canvas := NewCanvas(image.Pt(20, 20))
+	strokePolygon(canvas, rectOutline(image.Rect(5, 5, 15, 15)), &Stroke{Color: color.Black, Width: 2})
+	img := canvas.RGBA()
+
+	for _, p := range []image.Point{{X: 4, Y: 4}, {X: 15, Y: 4}, {X: 15, Y: 15}, {X: 4, Y: 15}, {X: 10, Y: 5}} {
+		if img.RGBAAt(p.X, p.Y).A == 0 {
+			t.Errorf("Point %v should be stroked", p)
+		}
+	}
+	if img.RGBAAt(10, 10).A != 0 || img.RGBAAt(3, 3).A != 0 {
+		t.Error("Stroke is too wide")
+	}
+}
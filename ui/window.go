@@ -141,25 +141,53 @@ func (pw *Visualizer) drawT() {
 	DrawT(pw.w, pw.center)
 }
 
-func DrawT(up screen.Uploader, p image.Point) {
-	colorT := color.RGBA{
-		R: 255,
-		G: 255,
-		B: 0,
-		A: 0,
+// TColor колір фігури T за замовчуванням.
+var TColor color.Color = color.RGBA{
+	R: 255,
+	G: 255,
+	B: 0,
+	A: 0,
+}
+
+// TRects повертає прямокутники, з яких складається фігура T з центром у точці p, розміри множаться на scale.
+func TRects(p image.Point, scale float64) [2]image.Rectangle {
+	sc := func(v int) int {
+		return int(float64(v) * scale)
 	}
 
-	up.Fill(
-		image.Rect(p.X-225, p.Y-175, p.X+225, p.Y),
-		colorT,
-		draw.Src,
-	)
-
-	up.Fill(
-		image.Rect(p.X-75, p.Y-175, p.X+75, p.Y+250),
-		colorT,
-		draw.Src,
-	)
+	return [2]image.Rectangle{
+		image.Rect(p.X-sc(225), p.Y-sc(175), p.X+sc(225), p.Y),
+		image.Rect(p.X-sc(75), p.Y-sc(175), p.X+sc(75), p.Y+sc(250)),
+	}
+}
+
+// TOutline повертає вершини контуру фігури T з центром у точці p за годинниковою стрілкою,
+// починаючи з лівого верхнього кута.
+func TOutline(p image.Point, scale float64) []image.Point {
+	r := TRects(p, scale)
+	bar, stem := r[0], r[1]
+
+	return []image.Point{
+		{X: bar.Min.X, Y: bar.Min.Y},
+		{X: bar.Max.X, Y: bar.Min.Y},
+		{X: bar.Max.X, Y: bar.Max.Y},
+		{X: stem.Max.X, Y: bar.Max.Y},
+		{X: stem.Max.X, Y: stem.Max.Y},
+		{X: stem.Min.X, Y: stem.Max.Y},
+		{X: stem.Min.X, Y: bar.Max.Y},
+		{X: bar.Min.X, Y: bar.Max.Y},
+	}
+}
+
+func DrawT(up screen.Uploader, p image.Point) {
+	DrawScaledT(up, p, 1, TColor)
+}
+
+// DrawScaledT малює фігуру T заданого кольору, збільшену у scale разів.
+func DrawScaledT(up screen.Uploader, p image.Point, scale float64, c color.Color) {
+	for _, r := range TRects(p, scale) {
+		up.Fill(r, c, draw.Src)
+	}
 }
 
 func (pw *Visualizer) drawDefaultUI() {